@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/scoring"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SpotChanges struct {
+	Id       int         `json:"id"`
+	Name     string      `json:"name"`
+	Timezone string      `json:"timezone"`
+	From     ForecastRun `json:"from"`
+	To       ForecastRun `json:"to"`
+	// mean rating delta over the compared hours, positive when the outlook improves
+	RatingDelta float64      `json:"rating_delta"`
+	Trend       string       `json:"trend"`
+	Changes     []HourChange `json:"changes"`
+}
+
+type ForecastRun struct {
+	RunId    int       `json:"run_id"`
+	Provider string    `json:"provider"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// Delta of a value between two runs, null when a run misses it
+type Delta struct {
+	From  *float64 `json:"from"`
+	To    *float64 `json:"to"`
+	Delta *float64 `json:"delta"`
+}
+
+type HourChange struct {
+	Time          time.Time `json:"time"`
+	WaveHeight    Delta     `json:"wave_height"`
+	SwellPeriod   Delta     `json:"swell_period"`
+	WindSpeed     Delta     `json:"wind_speed"`
+	WindDirection Delta     `json:"wind_direction"`
+	Rating        Delta     `json:"rating"`
+}
+
+// rating deltas smaller than this are reported as unchanged
+const trendThreshold = 0.1
+
+var errInvalidRequest = errors.New("invalid request")
+
+func newDelta(from, to float64) Delta {
+	delta := to - from
+	return Delta{From: &from, To: &to, Delta: &delta}
+}
+
+func nullFloatPtr(value sql.NullFloat64) *float64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Float64
+}
+
+func newNullDelta(from, to sql.NullFloat64) Delta {
+	if !from.Valid || !to.Valid {
+		return Delta{From: nullFloatPtr(from), To: nullFloatPtr(to)}
+	}
+	return newDelta(from.Float64, to.Float64)
+}
+
+// delta between two directions in degrees, between -180 and 180
+func newDirectionDelta(from, to sql.NullFloat64) Delta {
+	if !from.Valid || !to.Valid {
+		return Delta{From: nullFloatPtr(from), To: nullFloatPtr(to)}
+	}
+	delta := math.Mod(to.Float64-from.Float64+540, 360) - 180
+	return Delta{From: &from.Float64, To: &to.Float64, Delta: &delta}
+}
+
+func findSpot(spotId int) (config.SpotConfig, bool) {
+	for _, spot := range config.GetConfig().Spots {
+		if spot.Id == spotId {
+			return spot, true
+		}
+	}
+	return config.SpotConfig{}, false
+}
+
+func forecastRunToApi(run models.ForecastRun, location *time.Location) ForecastRun {
+	return ForecastRun{RunId: run.RunId, Provider: run.Provider, IssuedAt: run.IssuedAt.In(location)}
+}
+
+// compare the hours present in both runs, times in the given time zone
+func diffForecastRuns(spotConfig config.SpotConfig, from, to []models.Weather, location *time.Location) ([]HourChange, float64) {
+	// keyed by instant, the scanned times may not share their location
+	fromByTime := make(map[int64]models.Weather, len(from))
+	for _, weather := range from {
+		fromByTime[weather.Time.Unix()] = weather
+	}
+
+	var changes []HourChange
+	var ratingDeltaSum float64
+	for _, toWeather := range to {
+		fromWeather, ok := fromByTime[toWeather.Time.Unix()]
+		if !ok {
+			continue
+		}
+		change := HourChange{
+			Time:          toWeather.Time.In(location),
+			WaveHeight:    newNullDelta(fromWeather.WaveHeight, toWeather.WaveHeight),
+			SwellPeriod:   newNullDelta(fromWeather.SwellPeriod, toWeather.SwellPeriod),
+			WindSpeed:     newNullDelta(fromWeather.WindSpeed, toWeather.WindSpeed),
+			WindDirection: newDirectionDelta(fromWeather.WindDirection, toWeather.WindDirection),
+			Rating: newDelta(
+				scoring.CalculateScoreSpotByHour(spotConfig, fromWeather),
+				scoring.CalculateScoreSpotByHour(spotConfig, toWeather),
+			),
+		}
+		ratingDeltaSum += *change.Rating.Delta
+		changes = append(changes, change)
+	}
+
+	if len(changes) == 0 {
+		return changes, 0
+	}
+	return changes, ratingDeltaSum / float64(len(changes))
+}
+
+func trend(ratingDelta float64) string {
+	switch {
+	case ratingDelta >= trendThreshold:
+		return "better"
+	case ratingDelta <= -trendThreshold:
+		return "worse"
+	default:
+		return "unchanged"
+	}
+}
+
+// the runs to compare: from/to run ids if given, else the two most recent runs of the spot
+func getRunsToCompare(r *http.Request, spotId int) (models.ForecastRun, models.ForecastRun, error) {
+	query := r.URL.Query()
+	fromParam := query.Get("from")
+	toParam := query.Get("to")
+
+	if fromParam == "" && toParam == "" {
+		runs, err := WeatherModel.GetLatestForecastRuns(spotId, 2)
+		if err != nil {
+			return models.ForecastRun{}, models.ForecastRun{}, err
+		}
+		if len(runs) < 2 {
+			return models.ForecastRun{}, models.ForecastRun{}, sql.ErrNoRows
+		}
+		return runs[1], runs[0], nil
+	}
+
+	var runs [2]models.ForecastRun
+	for i, param := range []string{fromParam, toParam} {
+		runId, err := strconv.Atoi(param)
+		if err != nil {
+			return models.ForecastRun{}, models.ForecastRun{}, errInvalidRequest
+		}
+		runs[i], err = WeatherModel.GetForecastRun(runId)
+		if err != nil {
+			return models.ForecastRun{}, models.ForecastRun{}, err
+		}
+		if runs[i].SpotId != spotId {
+			return models.ForecastRun{}, models.ForecastRun{}, sql.ErrNoRows
+		}
+	}
+	return runs[0], runs[1], nil
+}
+
+// GetSpotChanges is a handler function that returns how the forecast of a spot changed between two runs
+func GetSpotChanges(w http.ResponseWriter, r *http.Request) {
+	spotId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	spotConfig, ok := findSpot(spotId)
+	if !ok {
+		http.Error(w, "Spot not found", http.StatusNotFound)
+		return
+	}
+
+	location, err := parseLocation(r)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if location == nil {
+		location = spotConfig.Location()
+	}
+
+	fromRun, toRun, err := getRunsToCompare(r, spotId)
+	if errors.Is(err, errInvalidRequest) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Forecast runs not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Could not get forecast runs", http.StatusInternalServerError)
+		return
+	}
+
+	fromWeather, err := WeatherModel.GetForecastRunWeather(fromRun.RunId)
+	if err != nil {
+		http.Error(w, "Could not get forecast run data", http.StatusInternalServerError)
+		return
+	}
+	toWeather, err := WeatherModel.GetForecastRunWeather(toRun.RunId)
+	if err != nil {
+		http.Error(w, "Could not get forecast run data", http.StatusInternalServerError)
+		return
+	}
+
+	if err := withTides(spotId, fromWeather); err != nil {
+		http.Error(w, "Could not get tide data", http.StatusInternalServerError)
+		return
+	}
+	if err := withTides(spotId, toWeather); err != nil {
+		http.Error(w, "Could not get tide data", http.StatusInternalServerError)
+		return
+	}
+
+	changes, ratingDelta := diffForecastRuns(spotConfig, fromWeather, toWeather, location)
+	response := SpotChanges{
+		Id:          spotConfig.Id,
+		Name:        spotConfig.Name,
+		Timezone:    location.String(),
+		From:        forecastRunToApi(fromRun, location),
+		To:          forecastRunToApi(toRun, location),
+		RatingDelta: ratingDelta,
+		Trend:       trend(ratingDelta),
+		Changes:     changes,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
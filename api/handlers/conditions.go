@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/resample"
+	"go-surf-forecast/internal/scoring"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SpotConditions struct {
+	Id         int          `json:"id"`
+	Name       string       `json:"name"`
+	Timezone   string       `json:"timezone"`
+	Conditions []Conditions `json:"conditions"`
+}
+
+// Conditions is the forecast of a spot for one hour, values the provider did not give are null
+type Conditions struct {
+	Time           time.Time `json:"time"`
+	WaveHeight     *float64  `json:"wave_height"`
+	WavePeriod     *float64  `json:"wave_period"`
+	WaveDirection  *float64  `json:"wave_direction"`
+	Swell          Swell     `json:"swell"`
+	SecondarySwell Swell     `json:"secondary_swell"`
+	WindWaves      Swell     `json:"wind_waves"`
+	WindSpeed      *float64  `json:"wind_speed"`
+	WindDirection  *float64  `json:"wind_direction"`
+	Gust           *float64  `json:"gust"`
+	// offshore, cross-offshore, cross, cross-onshore or onshore
+	Wind           string   `json:"wind,omitempty"`
+	AirTemperature *float64 `json:"air_temperature"`
+	// mm/h
+	Precipitation *float64 `json:"precipitation"`
+	// %
+	CloudCover *float64 `json:"cloud_cover"`
+	// km
+	Visibility *float64 `json:"visibility"`
+	// hPa
+	Pressure         *float64 `json:"pressure"`
+	WaterTemperature *float64 `json:"water_temperature"`
+	SeaLevel         *float64 `json:"sea_level"`
+	CurrentSpeed     *float64 `json:"current_speed"`
+	Tide             string   `json:"tide,omitempty"`
+	Interpolated     bool     `json:"interpolated,omitempty"`
+}
+
+// Swell is one partition of the sea state
+type Swell struct {
+	Height    *float64 `json:"height"`
+	Period    *float64 `json:"period"`
+	Direction *float64 `json:"direction"`
+}
+
+func newSwell(height, period, direction sql.NullFloat64) Swell {
+	return Swell{Height: nullFloatPtr(height), Period: nullFloatPtr(period), Direction: nullFloatPtr(direction)}
+}
+
+// map a weather row to the conditions of the API, time in the given time zone
+func conditionsToApi(spotConfig config.SpotConfig, weather models.Weather, location *time.Location) Conditions {
+	return Conditions{
+		Time:             weather.Time.In(location),
+		WaveHeight:       nullFloatPtr(weather.WaveHeight),
+		WavePeriod:       nullFloatPtr(weather.WavePeriod),
+		WaveDirection:    nullFloatPtr(weather.WaveDirection),
+		Swell:            newSwell(weather.SwellHeight, weather.SwellPeriod, weather.SwellDirection),
+		SecondarySwell:   newSwell(weather.SecondarySwellHeight, weather.SecondarySwellPeriod, weather.SecondarySwellDirection),
+		WindWaves:        newSwell(weather.WindWaveHeight, weather.WindWavePeriod, weather.WindWaveDirection),
+		WindSpeed:        nullFloatPtr(weather.WindSpeed),
+		WindDirection:    nullFloatPtr(weather.WindDirection),
+		Gust:             nullFloatPtr(weather.Gust),
+		Wind:             scoring.WindClass(spotConfig, weather),
+		AirTemperature:   nullFloatPtr(weather.AirTemperature),
+		Precipitation:    nullFloatPtr(weather.Precipitation),
+		CloudCover:       nullFloatPtr(weather.CloudCover),
+		Visibility:       nullFloatPtr(weather.Visibility),
+		Pressure:         nullFloatPtr(weather.Pressure),
+		WaterTemperature: nullFloatPtr(weather.WaterTemperature),
+		SeaLevel:         nullFloatPtr(weather.SeaLevel),
+		CurrentSpeed:     nullFloatPtr(weather.CurrentSpeed),
+		Tide:             tideToApi(weather.Tide),
+		Interpolated:     weather.Interpolated,
+	}
+}
+
+// GetSpotConditions is a handler function that returns the forecast parameters of a spot by hour
+func GetSpotConditions(w http.ResponseWriter, r *http.Request) {
+	spotId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	spotConfig, ok := findSpot(spotId)
+	if !ok {
+		http.Error(w, "Spot not found", http.StatusNotFound)
+		return
+	}
+	params, err := parseQueryParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	start, end := params.period(spotConfig)
+	weatherData, err := WeatherModel.GetWeatherDataFromDb(spotId, start, end, params.asOf)
+	if err != nil {
+		http.Error(w, "Could not get weather data", http.StatusInternalServerError)
+		return
+	}
+	if err := withTides(spotId, weatherData); err != nil {
+		http.Error(w, "Could not get tide data", http.StatusInternalServerError)
+		return
+	}
+
+	location := params.locationOf(spotConfig)
+	response := SpotConditions{
+		Id:         spotConfig.Id,
+		Name:       spotConfig.Name,
+		Timezone:   location.String(),
+		Conditions: []Conditions{},
+	}
+	for _, weather := range resample.Every(weatherData, params.granularity, location) {
+		response.Conditions = append(response.Conditions, conditionsToApi(spotConfig, weather, location))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"go-surf-forecast/config"
+	"go-surf-forecast/internal/ensemble"
 	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/resample"
 	"go-surf-forecast/internal/scoring"
 	"net/http"
 	"strconv"
@@ -16,58 +18,197 @@ type Response struct {
 }
 
 type SurfSpot struct {
-	Id      int              `json:"id"`
-	Name    string           `json:"name"`
-	Ratings []SurfSpotRating `json:"ratings"`
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	// time zone of the rating times
+	Timezone string           `json:"timezone"`
+	Ratings  []SurfSpotRating `json:"ratings"`
 }
 
 type SurfSpotRating struct {
 	Rating float64   `json:"rating"`
 	Time   time.Time `json:"time"`
+	// between 0 and 1, lowered by model disagreement and forecast lead time
+	Confidence float64 `json:"confidence"`
+	// tide stage and movement, empty without tide data
+	Tide string `json:"tide,omitempty"`
+	// offshore, cross-offshore, cross, cross-onshore or onshore, empty without wind data
+	Wind string `json:"wind,omitempty"`
+	// scoring parameters missing from the forecast, the rating is computed without them
+	Missing []string `json:"missing,omitempty"`
+	// the provider skipped the hour, its forecast is interpolated from the hours around it
+	Interpolated bool `json:"interpolated,omitempty"`
+	// components of the rating, only with explain=true
+	Components []ScoreComponent `json:"components,omitempty"`
+}
+
+// ScoreComponent is the part of a component in a rating
+type ScoreComponent struct {
+	// wave, swell, wind, comfort or tide
+	Name  string  `json:"name"`
+	Score float64 `json:"score"`
+	// share of the rating, 0 when the inputs are missing
+	Weight float64            `json:"weight"`
+	Inputs map[string]float64 `json:"inputs"`
+	Reason string             `json:"reason"`
 }
 
 var WeatherModel models.WeatherModel
 
-func parseQueryParams(r *http.Request) (time.Time, int, error) {
+type queryParams struct {
+	start time.Time
+	// start given as a date, the period then starts at the midnight of the spot time zone
+	startDate bool
+	duration  int
+	// zero when the latest forecast run must be used
+	asOf time.Time
+	// time zone overriding the ones of the spots, nil when not set
+	location *time.Location
+	// time between two ratings, 1, 3 or 6 hours
+	granularity time.Duration
+	// rider profile overriding the scoring of the spots, nil when not set
+	profile *config.ScoringConfig
+	// return the components of each rating
+	explain bool
+}
+
+// time zone of the tz query parameter, nil when not set
+func parseLocation(r *http.Request) (*time.Location, error) {
+	tzParam := r.URL.Query().Get("tz")
+	if tzParam == "" {
+		return nil, nil
+	}
+	return time.LoadLocation(tzParam)
+}
+
+// time zone the times of a spot are shown in
+func (p queryParams) locationOf(spot config.SpotConfig) *time.Location {
+	if p.location != nil {
+		return p.location
+	}
+	return spot.Location()
+}
+
+// spot scored with the rider profile
+func (p queryParams) scoredSpot(spot config.SpotConfig) config.SpotConfig {
+	if p.profile != nil {
+		spot.Scoring = spot.Scoring.Merge(*p.profile)
+	}
+	return spot
+}
+
+// period of a spot, from start to the midnight ending the duration-th day in the spot time zone
+func (p queryParams) period(spot config.SpotConfig) (time.Time, time.Time) {
+	location := p.locationOf(spot)
+	start := p.start.In(location)
+	if p.startDate {
+		// the date is parsed at midnight UTC, the day must not move with the time zone offset
+		start = time.Date(p.start.Year(), p.start.Month(), p.start.Day(), 0, 0, 0, 0, location)
+	}
+	end := time.Date(start.Year(), start.Month(), start.Day()+p.duration, 0, 0, 0, 0, location)
+	return start, end
+}
+
+func parseQueryParams(r *http.Request) (queryParams, error) {
 	query := r.URL.Query()
 	startParam := query.Get("start")
 	durationParam := query.Get("duration")
+	asOfParam := query.Get("as_of")
+	granularityParam := query.Get("granularity")
+	profileParam := query.Get("profile")
+	explainParam := query.Get("explain")
 
-	var start time.Time
+	var params queryParams
 	var err error
+	params.location, err = parseLocation(r)
+	if err != nil {
+		return queryParams{}, err
+	}
+
+	if asOfParam != "" {
+		params.asOf, err = time.Parse(time.RFC3339, asOfParam)
+		if err != nil {
+			return queryParams{}, err
+		}
+	}
+
 	if startParam == "" {
-		start = time.Now()
+		// looking at a past forecast starts where that forecast stood
+		if !params.asOf.IsZero() {
+			params.start = params.asOf
+		} else {
+			params.start = time.Now()
+		}
+	} else if params.start, err = time.Parse(time.DateOnly, startParam); err == nil {
+		params.startDate = true
 	} else {
-		start, err = time.Parse(time.RFC3339, startParam)
+		params.start, err = time.Parse(time.RFC3339, startParam)
 		if err != nil {
-			return time.Time{}, 0, err
+			return queryParams{}, err
 		}
 	}
 
-	duration := 7
+	params.duration = 7
 	if durationParam != "" {
-		duration, err = strconv.Atoi(durationParam)
-		if duration < 1 || duration > 7 {
-			return time.Time{}, 0, fmt.Errorf("duration must be between 1 and 7")
+		params.duration, err = strconv.Atoi(durationParam)
+		if params.duration < 1 || params.duration > 7 {
+			return queryParams{}, fmt.Errorf("duration must be between 1 and 7")
+		}
+		if err != nil {
+			return queryParams{}, err
+		}
+	}
+
+	params.granularity = time.Hour
+	if granularityParam != "" {
+		params.granularity, err = time.ParseDuration(granularityParam)
+		if err != nil {
+			return queryParams{}, err
+		}
+		if params.granularity != time.Hour && params.granularity != 3*time.Hour && params.granularity != 6*time.Hour {
+			return queryParams{}, fmt.Errorf("granularity must be 1h, 3h or 6h")
+		}
+	}
+
+	if profileParam != "" {
+		profile, ok := config.GetConfig().Profiles[profileParam]
+		if !ok {
+			return queryParams{}, fmt.Errorf("unknown profile %s", profileParam)
 		}
+		params.profile = &profile
+	}
+
+	if explainParam != "" {
+		params.explain, err = strconv.ParseBool(explainParam)
 		if err != nil {
-			return time.Time{}, 0, err
+			return queryParams{}, err
 		}
 	}
 
-	return start, duration, nil
+	return params, nil
 }
 
-// map weather data from database to API response
-func weatherDataToApi(spotConfig config.SpotConfig, weatherData []models.Weather) SurfSpot {
+// map weather data from database to API response, times in the given time zone,
+// with the components of the ratings when explain is set
+func weatherDataToApi(spotConfig config.SpotConfig, weatherData []models.Weather, location *time.Location, explain bool) SurfSpot {
 	spot := SurfSpot{
-		Id:   spotConfig.Id,
-		Name: spotConfig.Name,
+		Id:       spotConfig.Id,
+		Name:     spotConfig.Name,
+		Timezone: location.String(),
 	}
 	for _, weather := range weatherData {
+		score, components := scoring.ExplainScoreSpotByHour(spotConfig, weather)
 		rating := SurfSpotRating{
-			Rating: scoring.CalculateScoreSpotByHour(spotConfig, weather),
-			Time:   weather.Time,
+			Rating:       score,
+			Time:         weather.Time.In(location),
+			Confidence:   ensemble.Confidence(weather),
+			Tide:         tideToApi(weather.Tide),
+			Wind:         scoring.WindClass(spotConfig, weather),
+			Missing:      scoring.MissingInputs(weather),
+			Interpolated: weather.Interpolated,
+		}
+		if explain {
+			rating.Components = componentsToApi(components)
 		}
 		spot.Ratings = append(spot.Ratings, rating)
 	}
@@ -75,6 +216,20 @@ func weatherDataToApi(spotConfig config.SpotConfig, weatherData []models.Weather
 	return spot
 }
 
+func componentsToApi(components []scoring.Component) []ScoreComponent {
+	apiComponents := make([]ScoreComponent, 0, len(components))
+	for _, component := range components {
+		apiComponents = append(apiComponents, ScoreComponent{
+			Name:   component.Name,
+			Score:  component.Score,
+			Weight: component.Weight,
+			Inputs: component.Inputs,
+			Reason: component.Reason,
+		})
+	}
+	return apiComponents
+}
+
 func getBestSpotAtAnytime(spots []SurfSpot) SurfSpot {
 	var bestSpot SurfSpot
 	var highestScore float64
@@ -98,7 +253,7 @@ func Healtcheck(w http.ResponseWriter, r *http.Request) {
 
 // GetSpots is a handler function that returns score for all spots by hour
 func GetSpots(w http.ResponseWriter, r *http.Request) {
-	start, duration, err := parseQueryParams(r)
+	params, err := parseQueryParams(r)
 	if err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
@@ -106,13 +261,20 @@ func GetSpots(w http.ResponseWriter, r *http.Request) {
 	cfg := config.GetConfig()
 	var response Response
 	for _, spot := range cfg.Spots {
-		weatherData, err := WeatherModel.GetWeatherDataFromDb(spot.Id, start, duration)
+		start, end := params.period(spot)
+		weatherData, err := WeatherModel.GetWeatherDataFromDb(spot.Id, start, end, params.asOf)
 		if err != nil {
 			http.Error(w, "Could not get static data", http.StatusInternalServerError)
 			return
 		}
+		if err := withTides(spot.Id, weatherData); err != nil {
+			http.Error(w, "Could not get tide data", http.StatusInternalServerError)
+			return
+		}
 
-		spotData := weatherDataToApi(spot, weatherData)
+		location := params.locationOf(spot)
+		weatherData = resample.Every(weatherData, params.granularity, location)
+		spotData := weatherDataToApi(params.scoredSpot(spot), weatherData, location, params.explain)
 		response.Spots = append(response.Spots, spotData)
 	}
 
@@ -122,7 +284,7 @@ func GetSpots(w http.ResponseWriter, r *http.Request) {
 
 // GetBestSpot is a handler function that returns the spot with the best score at any time
 func GetBestSpot(w http.ResponseWriter, r *http.Request) {
-	start, duration, err := parseQueryParams(r)
+	params, err := parseQueryParams(r)
 	if err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
@@ -131,13 +293,20 @@ func GetBestSpot(w http.ResponseWriter, r *http.Request) {
 	var spots []SurfSpot
 	cfg := config.GetConfig()
 	for _, spotConfig := range cfg.Spots {
-		weatherData, err := WeatherModel.GetWeatherDataFromDb(spotConfig.Id, start, duration)
+		start, end := params.period(spotConfig)
+		weatherData, err := WeatherModel.GetWeatherDataFromDb(spotConfig.Id, start, end, params.asOf)
 		if err != nil {
 			http.Error(w, "Could not get static data", http.StatusInternalServerError)
 			return
 		}
+		if err := withTides(spotConfig.Id, weatherData); err != nil {
+			http.Error(w, "Could not get tide data", http.StatusInternalServerError)
+			return
+		}
 
-		spot := weatherDataToApi(spotConfig, weatherData)
+		location := params.locationOf(spotConfig)
+		weatherData = resample.Every(weatherData, params.granularity, location)
+		spot := weatherDataToApi(params.scoredSpot(spotConfig), weatherData, location, params.explain)
 		spots = append(spots, spot)
 	}
 
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"go-surf-forecast/config"
+	"testing"
+	"time"
+)
+
+func TestPeriod(t *testing.T) {
+	testCases := []struct {
+		label    string
+		timezone string
+		start    time.Time
+		date     bool
+		expected string
+	}{
+		{"date in UTC", "UTC", time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC), true, "2024-10-12T00:00:00Z"},
+		{"date east of UTC", "Europe/Paris", time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC), true, "2024-10-12T00:00:00+02:00"},
+		{"date far east of UTC", "Pacific/Auckland", time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC), true, "2024-10-12T00:00:00+13:00"},
+		{"date west of UTC", "America/Los_Angeles", time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC), true, "2024-10-12T00:00:00-07:00"},
+		{"date far west of UTC", "Pacific/Honolulu", time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC), true, "2024-10-12T00:00:00-10:00"},
+		{"instant west of UTC", "America/Los_Angeles", time.Date(2024, time.October, 12, 8, 0, 0, 0, time.UTC), false, "2024-10-12T01:00:00-07:00"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			location, err := time.LoadLocation(tc.timezone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := queryParams{start: tc.start, startDate: tc.date, duration: 2, location: location}
+			start, end := params.period(config.SpotConfig{})
+			if start.Format(time.RFC3339) != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, start.Format(time.RFC3339))
+			}
+			// the period ends at the midnight two days after the start day
+			expectedEnd := time.Date(start.Year(), start.Month(), start.Day()+2, 0, 0, 0, 0, location)
+			if !end.Equal(expectedEnd) {
+				t.Errorf("Expected %v, got %v", expectedEnd, end)
+			}
+		})
+	}
+}
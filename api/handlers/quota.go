@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+	"net/http"
+	"time"
+)
+
+type Quota struct {
+	Provider     string    `json:"provider"`
+	Day          string    `json:"day"`
+	DailyQuota   int       `json:"daily_quota"`
+	RequestCount int       `json:"request_count"`
+	Remaining    int       `json:"remaining"`
+	ResetsAt     time.Time `json:"resets_at"`
+}
+
+var QuotaModel models.QuotaModel
+
+// GetQuota is a handler function that returns the remaining stormglass requests for today
+func GetQuota(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().UTC()
+	quota, err := QuotaModel.GetQuota("stormglass", now)
+	if errors.Is(err, sql.ErrNoRows) {
+		// nothing sent today
+		quota = models.Quota{Provider: "stormglass", DailyQuota: config.GetConfig().Stormglass.DailyQuota}
+	} else if err != nil {
+		http.Error(w, "Could not get quota", http.StatusInternalServerError)
+		return
+	}
+
+	day := now.Truncate(24 * time.Hour)
+	response := Quota{
+		Provider:     quota.Provider,
+		Day:          day.Format(time.DateOnly),
+		DailyQuota:   quota.DailyQuota,
+		RequestCount: quota.RequestCount,
+		Remaining:    quota.Remaining(),
+		ResetsAt:     day.Add(24 * time.Hour),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/tide"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SpotTides struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	Timezone string `json:"timezone"`
+	Tides    []Tide `json:"tides"`
+}
+
+type Tide struct {
+	Time   time.Time `json:"time"`
+	Height float64   `json:"height"`
+	Type   string    `json:"type"`
+}
+
+var TideModel models.TideModel
+
+// set the tide of each hour of a spot from the stored tides, hours the tides do not cover keep none
+func withTides(spotId int, weatherData []models.Weather) error {
+	if len(weatherData) == 0 {
+		return nil
+	}
+	// extremes are at most a tide cycle apart
+	start := weatherData[0].Time.Add(-13 * time.Hour)
+	end := weatherData[len(weatherData)-1].Time.Add(13 * time.Hour)
+	tides, err := TideModel.GetTidesFromDb(spotId, start, end)
+	if err != nil {
+		return err
+	}
+
+	for i := range weatherData {
+		if state, ok := tide.State(tides, weatherData[i].Time); ok {
+			weatherData[i].Tide = &state
+		}
+	}
+	return nil
+}
+
+// describe the tide of an hour, e.g. "mid rising"
+func tideToApi(state *models.TideState) string {
+	if state == nil {
+		return ""
+	}
+	if state.Rising {
+		return state.Stage() + " rising"
+	}
+	return state.Stage() + " falling"
+}
+
+// GetSpotTides is a handler function that returns the high and low tides of a spot
+func GetSpotTides(w http.ResponseWriter, r *http.Request) {
+	spotId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	spotConfig, ok := findSpot(spotId)
+	if !ok {
+		http.Error(w, "Spot not found", http.StatusNotFound)
+		return
+	}
+	params, err := parseQueryParams(r)
+	if err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	start, end := params.period(spotConfig)
+	tides, err := TideModel.GetTidesFromDb(spotId, start, end)
+	if err != nil {
+		http.Error(w, "Could not get tide data", http.StatusInternalServerError)
+		return
+	}
+
+	location := params.locationOf(spotConfig)
+	response := SpotTides{
+		Id:       spotConfig.Id,
+		Name:     spotConfig.Name,
+		Timezone: location.String(),
+		Tides:    []Tide{},
+	}
+	for _, tide := range tides {
+		response.Tides = append(response.Tides, Tide{Time: tide.Time.In(location), Height: tide.Height, Type: tide.Type})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"go-surf-forecast/config"
+	"go-surf-forecast/internal/ingest"
+	"go-surf-forecast/internal/models"
 	"go-surf-forecast/internal/stormglass"
 	"log"
 	"os"
@@ -12,6 +15,22 @@ import (
 	_ "github.com/lib/pq"
 )
 
+// convert columns created as TIMESTAMP, holding UTC times, to TIMESTAMPTZ
+func migrateToTimestamptz(db *sql.DB, table string, columns ...string) {
+	for _, column := range columns {
+		migration := fmt.Sprintf(`DO $$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM information_schema.columns
+				WHERE table_name = '%[1]s' AND column_name = '%[2]s' AND data_type = 'timestamp without time zone') THEN
+				ALTER TABLE %[1]s ALTER COLUMN %[2]s TYPE TIMESTAMPTZ USING %[2]s AT TIME ZONE 'UTC';
+			END IF;
+		END $$`, table, column)
+		if _, err := db.Exec(migration); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
 func initSpotTable(db *sql.DB) {
 	spotTable := `CREATE TABLE IF NOT EXISTS spot (
 		spot_id SERIAL PRIMARY KEY,
@@ -41,10 +60,25 @@ func initSpotTable(db *sql.DB) {
 	log.Println("Spot data inserted successfully")
 }
 
-func initWeatherDataTable(db *sql.DB, dataSource string) {
+func initWeatherDataTable(db *sql.DB, ingester ingest.Ingester) {
+	forecastRunTable := `CREATE TABLE IF NOT EXISTS forecast_run (
+		run_id SERIAL PRIMARY KEY,
+		spot_id INT,
+		provider VARCHAR(255),
+		issued_at TIMESTAMPTZ,
+		FOREIGN KEY (spot_id) REFERENCES spot(spot_id)
+	);`
+	_, err := db.Exec(forecastRunTable)
+	if err != nil {
+		log.Fatal(err)
+	} else {
+		log.Println("Forecast run table created successfully")
+	}
+
 	weatherTable := `CREATE TABLE IF NOT EXISTS weather (
         spot_id INT,
-        timestamp TIMESTAMP,
+        run_id INT,
+        timestamp TIMESTAMPTZ,
         air_temperature FLOAT,
         current_speed FLOAT,
         sea_level FLOAT,
@@ -57,55 +91,179 @@ func initWeatherDataTable(db *sql.DB, dataSource string) {
         wave_period FLOAT,
         wind_direction FLOAT,
         wind_speed FLOAT,
-		PRIMARY KEY (spot_id, timestamp),
-		FOREIGN KEY (spot_id) REFERENCES spot(spot_id)
+        secondary_swell_direction FLOAT,
+        secondary_swell_height FLOAT,
+        secondary_swell_period FLOAT,
+        wind_wave_direction FLOAT,
+        wind_wave_height FLOAT,
+        wind_wave_period FLOAT,
+        gust FLOAT,
+        precipitation FLOAT,
+        cloud_cover FLOAT,
+        visibility FLOAT,
+        pressure FLOAT,
+        wave_height_spread FLOAT NOT NULL DEFAULT 0,
+        swell_period_spread FLOAT NOT NULL DEFAULT 0,
+        wind_speed_spread FLOAT NOT NULL DEFAULT 0,
+        daylight BOOLEAN,
+        interpolated BOOLEAN NOT NULL DEFAULT FALSE,
+		FOREIGN KEY (spot_id) REFERENCES spot(spot_id),
+		FOREIGN KEY (run_id) REFERENCES forecast_run(run_id)
     );`
-	_, err := db.Exec(weatherTable)
+	_, err = db.Exec(weatherTable)
 	if err != nil {
 		log.Fatal(err)
 	} else {
 		log.Println("Weather table created successfully")
 	}
 
-	cfg := config.GetConfig()
+	weatherSourceTable := `CREATE TABLE IF NOT EXISTS weather_source (
+		run_id INT,
+		timestamp TIMESTAMPTZ,
+		parameter VARCHAR(64),
+		source VARCHAR(64),
+		value FLOAT,
+		PRIMARY KEY (run_id, timestamp, parameter, source),
+		FOREIGN KEY (run_id) REFERENCES forecast_run(run_id)
+	);`
+	_, err = db.Exec(weatherSourceTable)
+	if err != nil {
+		log.Fatal(err)
+	} else {
+		log.Println("Weather source table created successfully")
+	}
 
-	var weatherData *stormglass.StormglassWeatherPointApiResponse
+	// weather tables created before forecast runs were keyed by (spot_id, timestamp),
+	// their rows have no run and are ignored by the API
+	weatherMigrations := []string{
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS run_id INT REFERENCES forecast_run(run_id)`,
+		`ALTER TABLE weather DROP CONSTRAINT IF EXISTS weather_pkey`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS weather_run_timestamp_idx ON weather (run_id, timestamp)`,
+		`CREATE INDEX IF NOT EXISTS weather_spot_timestamp_idx ON weather (spot_id, timestamp)`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS wave_height_spread FLOAT NOT NULL DEFAULT 0`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS swell_period_spread FLOAT NOT NULL DEFAULT 0`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS wind_speed_spread FLOAT NOT NULL DEFAULT 0`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS daylight BOOLEAN`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS interpolated BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS secondary_swell_direction FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS secondary_swell_height FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS secondary_swell_period FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS wind_wave_direction FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS wind_wave_height FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS wind_wave_period FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS gust FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS precipitation FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS cloud_cover FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS visibility FLOAT`,
+		`ALTER TABLE weather ADD COLUMN IF NOT EXISTS pressure FLOAT`,
+	}
+	for _, migration := range weatherMigrations {
+		if _, err := db.Exec(migration); err != nil {
+			log.Fatal(err)
+		}
+	}
+	migrateToTimestamptz(db, "forecast_run", "issued_at")
+	migrateToTimestamptz(db, "weather", "timestamp")
+	migrateToTimestamptz(db, "weather_source", "timestamp")
 
-	for _, spot := range cfg.Spots {
+	cfg := config.GetConfig()
 
+	for _, spot := range cfg.Spots {
 		duration := 7
-		if dataSource == "stormglass" {
-			start := time.Now()
-			weatherData, err = stormglass.GetStormglassWeatherDataFromApi(spot, start, duration)
-			if err != nil {
-				log.Fatal(err)
-			}
-		} else {
-			start := time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC)
-			weatherData, err = stormglass.GetStormglassWeatherDataFromFile(spot, start, duration)
-			if err != nil {
-				log.Fatal(err)
-			}
+		start := time.Now()
+		// a failing spot must not prevent the API from starting, the scheduler will retry it
+		if err := ingester.IngestSpot(context.Background(), spot, start, duration); err != nil {
+			log.Printf("Could not init weather data for spot %d: %v", spot.Id, err)
+			continue
 		}
+		log.Println("Weather data inserted successfully for spot", spot.Id)
+	}
+}
 
-		for _, data := range weatherData.Hours {
-			_, err := db.Exec(`INSERT INTO weather(
-            spot_id, timestamp, air_temperature, current_speed, sea_level, swell_direction, 
-            swell_height, swell_period, water_temperature, wave_direction, wave_height, 
-            wave_period, wind_direction, wind_speed) 
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
-			ON CONFLICT (spot_id, timestamp) DO NOTHING`,
-				spot.Id, data.Time, data.AirTemperature.Sg, data.CurrentSpeed.Sg, data.SeaLevel.Sg,
-				data.SwellDirection.Sg, data.SwellHeight.Sg, data.SwellPeriod.Sg, data.WaterTemperature.Sg,
-				data.WaveDirection.Sg, data.WaveHeight.Sg, data.WavePeriod.Sg, data.WindDirection.Sg, data.WindSpeed.Sg)
-			if err != nil {
-				log.Fatal(err)
-			}
+func initTideTable(db *sql.DB, ingester ingest.Ingester) {
+	tideTable := `CREATE TABLE IF NOT EXISTS tide (
+		spot_id INT,
+		timestamp TIMESTAMPTZ,
+		height FLOAT,
+		type VARCHAR(8),
+		source VARCHAR(64),
+		PRIMARY KEY (spot_id, timestamp),
+		FOREIGN KEY (spot_id) REFERENCES spot(spot_id)
+	);`
+	_, err := db.Exec(tideTable)
+	if err != nil {
+		log.Fatal(err)
+	} else {
+		log.Println("Tide table created successfully")
+	}
+	migrateToTimestamptz(db, "tide", "timestamp")
+
+	cfg := config.GetConfig()
+
+	for _, spot := range cfg.Spots {
+		duration := 7
+		start := time.Now()
+		if err := ingester.IngestTides(context.Background(), spot, start, duration); err != nil {
+			log.Printf("Could not init tide data for spot %d: %v", spot.Id, err)
 		}
-		log.Println("Weather data inserted successfully for spot", spot.Id)
 	}
 }
 
+func initQuarantineTable(db *sql.DB) {
+	quarantineTable := `CREATE TABLE IF NOT EXISTS weather_quarantine (
+		quarantine_id SERIAL PRIMARY KEY,
+		spot_id INT,
+		provider VARCHAR(255),
+		timestamp TIMESTAMPTZ,
+		reason TEXT,
+		data JSONB,
+		quarantined_at TIMESTAMPTZ,
+		FOREIGN KEY (spot_id) REFERENCES spot(spot_id)
+	);`
+	_, err := db.Exec(quarantineTable)
+	if err != nil {
+		log.Fatal(err)
+	} else {
+		log.Println("Weather quarantine table created successfully")
+	}
+}
+
+func initRefreshTable(db *sql.DB) {
+	refreshTable := `CREATE TABLE IF NOT EXISTS spot_refresh (
+		spot_id INT PRIMARY KEY,
+		last_attempt TIMESTAMPTZ,
+		last_success TIMESTAMPTZ,
+		last_error TEXT,
+		consecutive_failures INT NOT NULL DEFAULT 0,
+		FOREIGN KEY (spot_id) REFERENCES spot(spot_id)
+	);`
+	_, err := db.Exec(refreshTable)
+	if err != nil {
+		log.Fatal(err)
+	} else {
+		log.Println("Spot refresh table created successfully")
+	}
+	migrateToTimestamptz(db, "spot_refresh", "last_attempt", "last_success")
+}
+
+func initQuotaTable(db *sql.DB) {
+	quotaTable := `CREATE TABLE IF NOT EXISTS provider_quota (
+		provider VARCHAR(255),
+		day DATE,
+		request_count INT NOT NULL DEFAULT 0,
+		daily_quota INT NOT NULL DEFAULT 0,
+		updated_at TIMESTAMPTZ,
+		PRIMARY KEY (provider, day)
+	);`
+	_, err := db.Exec(quotaTable)
+	if err != nil {
+		log.Fatal(err)
+	} else {
+		log.Println("Provider quota table created successfully")
+	}
+	migrateToTimestamptz(db, "provider_quota", "updated_at")
+}
+
 func main() {
 	cfg, err := config.LoadConfig("config/config.yaml")
 	if err != nil {
@@ -118,10 +276,6 @@ func main() {
 	postgresUser := os.Getenv("POSTGRES_USER")
 	postgresPassword := os.Getenv("POSTGRES_PASSWORD")
 	postgresDb := os.Getenv("POSTGRES_DB")
-	weatherDataSource := cfg.WeatherData.Source
-	if weatherDataSource == "" {
-		weatherDataSource = "file"
-	}
 
 	if postgresUser == "" || postgresPassword == "" || postgresDb == "" {
 		log.Fatal("POSTGRES_USER, POSTGRES_PASSWORD and POSTGRES_DB must be set")
@@ -134,9 +288,20 @@ func main() {
 	}
 	defer db.Close()
 
+	ingester, err := ingest.New(db, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	initSpotTable(db)
-	log.Printf("Using data source = %s to init weather db...", weatherDataSource)
-	initWeatherDataTable(db, weatherDataSource)
+	initRefreshTable(db)
+	initQuotaTable(db)
+	initQuarantineTable(db)
+	quotaTracker := stormglass.NewQuotaTracker(models.QuotaModel{DB: db}, cfg.Stormglass.DailyQuota)
+	stormglass.SetDefaultClient(stormglass.NewClient(cfg.Stormglass, quotaTracker))
+	log.Printf("Using data source = %s to init weather db...", ingester.Provider.Name())
+	initWeatherDataTable(db, ingester)
+	initTideTable(db, ingester)
 	log.Println("Database setup completed successfully.")
 
 }
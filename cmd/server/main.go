@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -9,7 +10,10 @@ import (
 
 	"go-surf-forecast/api/handlers"
 	"go-surf-forecast/config"
+	"go-surf-forecast/internal/ingest"
 	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/scheduler"
+	"go-surf-forecast/internal/stormglass"
 
 	_ "github.com/lib/pq"
 )
@@ -34,10 +38,26 @@ func main() {
 	defer db.Close()
 
 	handlers.WeatherModel = models.WeatherModel{DB: db}
+	handlers.QuotaModel = models.QuotaModel{DB: db}
+	handlers.TideModel = models.TideModel{DB: db}
+	quotaTracker := stormglass.NewQuotaTracker(handlers.QuotaModel, cfg.Stormglass.DailyQuota)
+	stormglass.SetDefaultClient(stormglass.NewClient(cfg.Stormglass, quotaTracker))
+
+	if cfg.Scheduler.Enabled {
+		ingester, err := ingest.New(db, cfg)
+		if err != nil {
+			log.Fatalf("Failed to start scheduler: %v", err)
+		}
+		go scheduler.New(ingester, cfg.Spots, cfg.Scheduler).Run(context.Background())
+	}
 
 	http.HandleFunc("/api/healthcheck", handlers.Healtcheck)
 	http.HandleFunc("/api/spots", handlers.GetSpots)
 	http.HandleFunc("/api/spots/best", handlers.GetBestSpot)
+	http.HandleFunc("/api/spots/{id}/changes", handlers.GetSpotChanges)
+	http.HandleFunc("/api/spots/{id}/tides", handlers.GetSpotTides)
+	http.HandleFunc("/api/spots/{id}/conditions", handlers.GetSpotConditions)
+	http.HandleFunc("/api/quota", handlers.GetQuota)
 
 	log.Println("Starting server on :8080")
 	err = http.ListenAndServe(":8080", nil)
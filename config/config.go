@@ -1,8 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"sync"
+	"time"
+	// embedded time zone database, spot time zones resolve on hosts without one
+	_ "time/tzdata"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,21 +17,242 @@ type SpotConfig struct {
 	Lat       float64 `yaml:"latitude"`
 	Long      float64 `yaml:"longitude"`
 	Direction int     `yaml:"direction"`
+	// direction the beach faces, towards the sea, used to tell offshore from onshore winds. Direction when not set
+	Facing *float64 `yaml:"facing"`
+	// arcs of swell directions the spot works with, around Direction when empty
+	SwellWindow []SwellArcConfig `yaml:"swell_window"`
+	// IANA time zone the spot times are shown in, UTC when not set
+	Timezone string `yaml:"timezone"`
+	// source (or "blend") to score from by stormglass parameter, "default" applies to the others
+	Sources map[string]string `yaml:"sources"`
+	Tide    TideConfig        `yaml:"tide"`
+	// tide the spot works at, ignored by the score while its weight is 0
+	TidePreference TidePreferenceConfig `yaml:"tide_preference"`
+	// overrides of the global scoring section, merged into it by LoadConfig
+	Scoring ScoringConfig `yaml:"scoring"`
+}
+
+// ScoringConfig tunes the score. Fields not set in a spot take the value of the scoring section,
+// then the one of DefaultScoring
+type ScoringConfig struct {
+	Weights     ScoringWeightsConfig `yaml:"weights"`
+	WaveHeight  RangeConfig          `yaml:"wave_height"`  // ideal wave height in m
+	SwellPeriod *float64             `yaml:"swell_period"` // s, longer periods score as well
+	Temperature *float64             `yaml:"temperature"`  // ideal water and air temperature in °C
+	LightWind   *float64             `yaml:"light_wind"`   // m/s, strongest light wind, the stronger bands scale with it
+}
+
+// ScoringWeightsConfig shares the score between its components, relative to each other
+type ScoringWeightsConfig struct {
+	Wave    *float64 `yaml:"wave"`
+	Swell   *float64 `yaml:"swell"`
+	Wind    *float64 `yaml:"wind"`
+	Comfort *float64 `yaml:"comfort"`
+}
+
+type RangeConfig struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+func float(value float64) *float64 {
+	return &value
+}
+
+// DefaultScoring is the scoring of the spots when the config sets none
+var DefaultScoring = ScoringConfig{
+	Weights:     ScoringWeightsConfig{Wave: float(0.5), Swell: float(0.25), Wind: float(0.2), Comfort: float(0.05)},
+	WaveHeight:  RangeConfig{Min: float(0.8), Max: float(2.0)},
+	SwellPeriod: float(10),
+	Temperature: float(22),
+	LightWind:   float(5),
+}
+
+// DefaultProfiles are the rider profiles available when the config does not redefine them
+var DefaultProfiles = map[string]ScoringConfig{
+	// longboarders learning in small waves, the wind matters more
+	"beginner": {
+		Weights:     ScoringWeightsConfig{Wave: float(0.45), Swell: float(0.2), Wind: float(0.3), Comfort: float(0.05)},
+		WaveHeight:  RangeConfig{Min: float(0.5), Max: float(1.0)},
+		SwellPeriod: float(8),
+		LightWind:   float(3),
+	},
+	"intermediate": {
+		WaveHeight: RangeConfig{Min: float(0.8), Max: float(1.8)},
+	},
+	// shortboarders looking for size and long period swells
+	"advanced": {
+		Weights:     ScoringWeightsConfig{Wave: float(0.45), Swell: float(0.35), Wind: float(0.15), Comfort: float(0.05)},
+		WaveHeight:  RangeConfig{Min: float(1.5), Max: float(3.0)},
+		SwellPeriod: float(13),
+		LightWind:   float(6),
+	},
+}
+
+// Merge returns the scoring with the fields set in override replaced
+func (s ScoringConfig) Merge(override ScoringConfig) ScoringConfig {
+	merge := func(value, override *float64) *float64 {
+		if override != nil {
+			return override
+		}
+		return value
+	}
+	return ScoringConfig{
+		Weights: ScoringWeightsConfig{
+			Wave:    merge(s.Weights.Wave, override.Weights.Wave),
+			Swell:   merge(s.Weights.Swell, override.Weights.Swell),
+			Wind:    merge(s.Weights.Wind, override.Weights.Wind),
+			Comfort: merge(s.Weights.Comfort, override.Weights.Comfort),
+		},
+		WaveHeight: RangeConfig{
+			Min: merge(s.WaveHeight.Min, override.WaveHeight.Min),
+			Max: merge(s.WaveHeight.Max, override.WaveHeight.Max),
+		},
+		SwellPeriod: merge(s.SwellPeriod, override.SwellPeriod),
+		Temperature: merge(s.Temperature, override.Temperature),
+		LightWind:   merge(s.LightWind, override.LightWind),
+	}
+}
+
+// check a scoring merged into DefaultScoring
+func (s ScoringConfig) validate() error {
+	weights := []*float64{s.Weights.Wave, s.Weights.Swell, s.Weights.Wind, s.Weights.Comfort}
+	var sum float64
+	for _, weight := range weights {
+		if *weight < 0 {
+			return fmt.Errorf("scoring weights must not be negative, got %g", *weight)
+		}
+		sum += *weight
+	}
+	if sum == 0 {
+		return fmt.Errorf("at least one scoring weight must be set")
+	}
+	if *s.WaveHeight.Min <= 0 || *s.WaveHeight.Max < *s.WaveHeight.Min {
+		return fmt.Errorf("scoring wave height must be a positive range, got %g to %g", *s.WaveHeight.Min, *s.WaveHeight.Max)
+	}
+	if *s.SwellPeriod <= 0 {
+		return fmt.Errorf("scoring swell period must be positive, got %g", *s.SwellPeriod)
+	}
+	if *s.LightWind <= 0 {
+		return fmt.Errorf("scoring light wind must be positive, got %g", *s.LightWind)
+	}
+	if *s.Temperature < 0 || *s.Temperature > 40 {
+		return fmt.Errorf("scoring temperature must be between 0 and 40 °C, got %g", *s.Temperature)
+	}
+	return nil
+}
+
+// SwellArcConfig is a sector of swell directions (where the swell comes from) open to a spot,
+// clockwise from From to To in degrees
+type SwellArcConfig struct {
+	From float64 `yaml:"from"`
+	To   float64 `yaml:"to"`
+	Edge float64 `yaml:"edge"` // degrees out of the arc over which the swell fades out, 20 when not set
+}
+
+type TidePreferenceConfig struct {
+	Weight    float64  `yaml:"weight"`     // share of the score given to the tide, between 0 and 1
+	Stages    []string `yaml:"stages"`     // low, mid and/or high, any stage when empty
+	Movement  string   `yaml:"movement"`   // rising or falling, both when empty
+	MinHeight *float64 `yaml:"min_height"` // sea level range in m, unbounded when not set
+	MaxHeight *float64 `yaml:"max_height"`
+}
+
+// check the tide preference, a typo would otherwise silently score every hour 0 or ignore the movement
+func (t TidePreferenceConfig) validate() error {
+	if t.Weight < 0 || t.Weight > 1 {
+		return fmt.Errorf("tide preference weight must be between 0 and 1, got %g", t.Weight)
+	}
+	for _, stage := range t.Stages {
+		if stage != "low" && stage != "mid" && stage != "high" {
+			return fmt.Errorf("tide preference stage must be low, mid or high, got %q", stage)
+		}
+	}
+	if t.Movement != "" && t.Movement != "rising" && t.Movement != "falling" {
+		return fmt.Errorf("tide preference movement must be rising or falling, got %q", t.Movement)
+	}
+	if t.MinHeight != nil && t.MaxHeight != nil && *t.MinHeight > *t.MaxHeight {
+		return fmt.Errorf("tide preference height range must not be empty, got %g to %g", *t.MinHeight, *t.MaxHeight)
+	}
+	return nil
+}
+
+// TideConfig holds the harmonic constants used to predict the tide of a spot
+type TideConfig struct {
+	Datum        float64                 `yaml:"datum"` // mean sea level in m
+	Constituents []TideConstituentConfig `yaml:"constituents"`
+}
+
+type TideConstituentConfig struct {
+	Name      string  `yaml:"name"`
+	Amplitude float64 `yaml:"amplitude"` // m
+	Phase     float64 `yaml:"phase"`     // Greenwich phase lag in degrees
+}
+
+// HttpClientConfig tunes the resilience of an API client, zero values use the client defaults
+type HttpClientConfig struct {
+	Timeout          time.Duration `yaml:"timeout"`
+	MaxRetries       int           `yaml:"max_retries"`
+	BaseBackoff      time.Duration `yaml:"base_backoff"`
+	MaxBackoff       time.Duration `yaml:"max_backoff"`
+	RateLimit        float64       `yaml:"rate_limit"`
+	RateBurst        int           `yaml:"rate_burst"`
+	BreakerThreshold int           `yaml:"breaker_threshold"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown"`
 }
 
 type StormglassConfig struct {
-	Url    string `yaml:"url"`
-	ApiKey string `yaml:"api_key"`
+	Url        string   `yaml:"url"`
+	ApiKey     string   `yaml:"api_key"`
+	DailyQuota int      `yaml:"daily_quota"`
+	Sources    []string `yaml:"sources"`
+	// weight of each source in blends and spreads, 1 when not set
+	Weights map[string]float64 `yaml:"weights"`
+	Http    HttpClientConfig   `yaml:"http"`
+}
+
+type OpenMeteoConfig struct {
+	MarineUrl   string           `yaml:"marine_url"`
+	ForecastUrl string           `yaml:"forecast_url"`
+	Http        HttpClientConfig `yaml:"http"`
 }
 
 type WeatherDataConfig struct {
 	Source string `yaml:"source"`
 }
 
+type InterpolationConfig struct {
+	// longest span between two forecast hours whose missing hours are interpolated, 6h when not set
+	MaxGap time.Duration `yaml:"max_gap"`
+}
+
+type SchedulerConfig struct {
+	Enabled    bool          `yaml:"enabled"`
+	Interval   time.Duration `yaml:"interval"`
+	RetryDelay time.Duration `yaml:"retry_delay"`
+}
+
 type Config struct {
-	Spots       []SpotConfig      `yaml:"spots"`
-	Stormglass  StormglassConfig  `yaml:"stormglass"`
-	WeatherData WeatherDataConfig `yaml:"weather_data"`
+	Spots         []SpotConfig        `yaml:"spots"`
+	Stormglass    StormglassConfig    `yaml:"stormglass"`
+	OpenMeteo     OpenMeteoConfig     `yaml:"open_meteo"`
+	WeatherData   WeatherDataConfig   `yaml:"weather_data"`
+	TideData      WeatherDataConfig   `yaml:"tide_data"`
+	Interpolation InterpolationConfig `yaml:"interpolation"`
+	Scheduler     SchedulerConfig     `yaml:"scheduler"`
+	// scoring of every spot, each field can be overridden by a spot
+	Scoring ScoringConfig `yaml:"scoring"`
+	// rider profiles by name, overriding the scoring of the spots when requested
+	Profiles map[string]ScoringConfig `yaml:"profiles"`
+}
+
+// Location returns the time zone of the spot, LoadConfig has checked it exists
+func (s SpotConfig) Location() *time.Location {
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
 }
 
 var (
@@ -47,6 +272,43 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, err
 	}
 
+	cfg.Scoring = DefaultScoring.Merge(cfg.Scoring)
+	if err := cfg.Scoring.validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Profiles == nil {
+		cfg.Profiles = make(map[string]ScoringConfig, len(DefaultProfiles))
+	}
+	for name, profile := range DefaultProfiles {
+		if _, ok := cfg.Profiles[name]; !ok {
+			cfg.Profiles[name] = profile
+		}
+	}
+
+	for i, spot := range cfg.Spots {
+		cfg.Spots[i].Scoring = cfg.Scoring.Merge(spot.Scoring)
+		if err := cfg.Spots[i].Scoring.validate(); err != nil {
+			return nil, fmt.Errorf("spot %d: %w", spot.Id, err)
+		}
+		for name, profile := range cfg.Profiles {
+			if err := cfg.Spots[i].Scoring.Merge(profile).validate(); err != nil {
+				return nil, fmt.Errorf("spot %d with profile %s: %w", spot.Id, name, err)
+			}
+		}
+		if err := spot.TidePreference.validate(); err != nil {
+			return nil, fmt.Errorf("spot %d: %w", spot.Id, err)
+		}
+		if _, err := time.LoadLocation(spot.Timezone); err != nil {
+			return nil, fmt.Errorf("spot %d: %w", spot.Id, err)
+		}
+		for _, arc := range spot.SwellWindow {
+			if arc.From < 0 || arc.From > 360 || arc.To < 0 || arc.To > 360 || arc.Edge < 0 {
+				return nil, fmt.Errorf("spot %d: swell window arc directions must be between 0 and 360 and its edge positive", spot.Id)
+			}
+		}
+	}
+
 	return &cfg, nil
 }
 
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadConfigScoring(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `
+scoring:
+  weights:
+    wind: 0.3
+spots:
+  - id: 1
+    scoring:
+      wave_height: {min: 1.2}
+      weights: {comfort: 0}
+  - id: 2
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		label    string
+		value    *float64
+		expected float64
+	}{
+		{"global weight", cfg.Spots[0].Scoring.Weights.Wind, 0.3},
+		{"spot override", cfg.Spots[0].Scoring.WaveHeight.Min, 1.2},
+		{"spot override to 0", cfg.Spots[0].Scoring.Weights.Comfort, 0},
+		{"default", cfg.Spots[0].Scoring.WaveHeight.Max, 2.0},
+		{"global weight of another spot", cfg.Spots[1].Scoring.Weights.Wind, 0.3},
+		{"default of another spot", cfg.Spots[1].Scoring.WaveHeight.Min, 0.8},
+		{"default weight of another spot", cfg.Spots[1].Scoring.Weights.Comfort, 0.05},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			if tc.value == nil || *tc.value != tc.expected {
+				t.Errorf("Expected %f, got %v", tc.expected, tc.value)
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalidScoring(t *testing.T) {
+	testCases := []struct {
+		label   string
+		content string
+	}{
+		{"negative weight", "scoring:\n  weights: {wind: -1}\n"},
+		{"no weight", "scoring:\n  weights: {wave: 0, swell: 0, wind: 0, comfort: 0}\n"},
+		{"empty wave height range", "spots:\n  - id: 1\n    scoring:\n      wave_height: {min: 2.5}\n"},
+		{"zero swell period", "spots:\n  - id: 1\n    scoring:\n      swell_period: 0\n"},
+		{"temperature in Fahrenheit", "scoring:\n  temperature: 72\n"},
+		{"negative temperature", "spots:\n  - id: 1\n    scoring:\n      temperature: -5\n"},
+		{"unknown tide stage", "spots:\n  - id: 1\n    tide_preference:\n      weight: 0.2\n      stages: [hihg]\n"},
+		{"unknown tide movement", "spots:\n  - id: 1\n    tide_preference:\n      weight: 0.2\n      movement: rsing\n"},
+		{"negative tide weight", "spots:\n  - id: 1\n    tide_preference:\n      weight: -0.2\n"},
+		{"tide weight above 1", "spots:\n  - id: 1\n    tide_preference:\n      weight: 1.5\n"},
+		{"empty tide height range", "spots:\n  - id: 1\n    tide_preference:\n      min_height: 2\n      max_height: 1\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			if _, err := LoadConfig(writeConfig(t, tc.content)); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadConfigProfiles(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `
+profiles:
+  kids:
+    wave_height: {min: 0.3, max: 0.8}
+spots:
+  - id: 1
+`))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, name := range []string{"beginner", "intermediate", "advanced", "kids"} {
+		if _, ok := cfg.Profiles[name]; !ok {
+			t.Errorf("Expected profile %s", name)
+		}
+	}
+
+	// the profile range is empty at this spot
+	_, err = LoadConfig(writeConfig(t, `
+profiles:
+  chargers:
+    wave_height: {max: 1.0}
+spots:
+  - id: 1
+    scoring:
+      wave_height: {min: 1.5, max: 4.0}
+`))
+	if err == nil {
+		t.Errorf("Expected an error")
+	}
+}
@@ -4,4 +4,4 @@ go 1.23.2
 
 require github.com/lib/pq v1.10.9
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
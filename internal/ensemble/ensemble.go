@@ -0,0 +1,107 @@
+package ensemble
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+// Blend is the choice selecting the weighted mean of every available source instead of a single one
+const Blend = "blend"
+
+// spreads at which the sources are considered in full disagreement
+const (
+	waveHeightSpreadScale  = 0.5 // m
+	swellPeriodSpreadScale = 3.0 // s
+	windSpeedSpreadScale   = 4.0 // m/s
+	// lead time dividing the confidence by e
+	leadTimeScale = 7 * 24 * time.Hour
+)
+
+// weight of a source, sources without a configured weight count for 1
+func weight(weights map[string]float64, source string) float64 {
+	if w, ok := weights[source]; ok {
+		return w
+	}
+	return 1
+}
+
+// Select returns the value to score from for one parameter: the value of the source named by
+// choice, or the weighted mean of all sources when choice is Blend. Directions are averaged on
+// the circle. It returns false when the chosen source has no value
+func Select(values map[string]float64, choice string, weights map[string]float64, circular bool) (float64, bool) {
+	if choice != Blend {
+		value, ok := values[choice]
+		return value, ok
+	}
+	mean, _, ok := Stats(values, weights, circular)
+	return mean, ok
+}
+
+// Stats returns the weighted mean and standard deviation of the source values,
+// the circular mean and standard deviation in degrees for directions
+func Stats(values map[string]float64, weights map[string]float64, circular bool) (float64, float64, bool) {
+	var weightSum, sum, sinSum, cosSum float64
+	for _, source := range sortedSources(values) {
+		w := weight(weights, source)
+		weightSum += w
+		sum += w * values[source]
+		rad := values[source] * math.Pi / 180
+		sinSum += w * math.Sin(rad)
+		cosSum += w * math.Cos(rad)
+	}
+	if weightSum <= 0 {
+		return 0, 0, false
+	}
+
+	if circular {
+		mean := math.Mod(math.Atan2(sinSum, cosSum)*180/math.Pi+360, 360)
+		// mean resultant length, 1 when every direction is the same
+		r := math.Min(1, math.Hypot(sinSum, cosSum)/weightSum)
+		if r <= 0 {
+			return mean, 180, true
+		}
+		return mean, math.Sqrt(-2*math.Log(r)) * 180 / math.Pi, true
+	}
+
+	mean := sum / weightSum
+	var variance float64
+	for _, source := range sortedSources(values) {
+		variance += weight(weights, source) * math.Pow(values[source]-mean, 2)
+	}
+	return mean, math.Sqrt(variance / weightSum), true
+}
+
+// Spread returns the weighted standard deviation of the source values, 0 for a single source
+func Spread(values map[string]float64, weights map[string]float64, circular bool) float64 {
+	_, spread, _ := Stats(values, weights, circular)
+	return spread
+}
+
+// Confidence returns a value between 0 and 1 telling how much a forecast hour can be trusted,
+// lowered by the disagreement between sources and by the time between the run and the hour
+func Confidence(weather models.Weather) float64 {
+	disagreement := (math.Min(1, weather.WaveHeightSpread/waveHeightSpreadScale) +
+		math.Min(1, weather.SwellPeriodSpread/swellPeriodSpreadScale) +
+		math.Min(1, weather.WindSpeedSpread/windSpeedSpreadScale)) / 3
+
+	leadFactor := 1.0
+	if !weather.IssuedAt.IsZero() {
+		leadTime := max(0, weather.Time.Sub(weather.IssuedAt))
+		leadFactor = math.Exp(-leadTime.Hours() / leadTimeScale.Hours())
+	}
+
+	return leadFactor * (1 - disagreement)
+}
+
+// sources in a stable order, so floating point sums don't depend on map iteration
+func sortedSources(values map[string]float64) []string {
+	sources := make([]string, 0, len(values))
+	for source := range values {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
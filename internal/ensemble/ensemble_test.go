@@ -0,0 +1,104 @@
+package ensemble
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+func TestSelect(t *testing.T) {
+	values := map[string]float64{"sg": 1.0, "noaa": 2.0, "icon": 3.0}
+	directions := map[string]float64{"sg": 350.0, "noaa": 10.0}
+
+	testCases := []struct {
+		values   map[string]float64
+		choice   string
+		circular bool
+		expected float64
+		ok       bool
+	}{
+		{values, "noaa", false, 2.0, true},
+		{values, "dwd", false, 0.0, false},
+		{values, Blend, false, 2.0, true},
+		{directions, Blend, true, 0.0, true},
+		{map[string]float64{}, Blend, false, 0.0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing %s selection of %v", tc.choice, tc.values)
+			result, ok := Select(tc.values, tc.choice, nil, tc.circular)
+			if ok != tc.ok || math.Abs(result-tc.expected) > 1e-9 {
+				t.Errorf("Expected %f %t, got %f %t", tc.expected, tc.ok, result, ok)
+			}
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	testCases := []struct {
+		values         map[string]float64
+		weights        map[string]float64
+		circular       bool
+		expectedMean   float64
+		expectedSpread float64
+	}{
+		{map[string]float64{"sg": 1.0}, nil, false, 1.0, 0.0},
+		{map[string]float64{"sg": 1.0, "noaa": 3.0}, nil, false, 2.0, 1.0},
+		{map[string]float64{"sg": 1.0, "noaa": 3.0}, map[string]float64{"noaa": 3}, false, 2.5, math.Sqrt(0.75)},
+		{map[string]float64{"sg": 90.0, "noaa": 90.0}, nil, true, 90.0, 0.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing stats of %v with weights %v", tc.values, tc.weights)
+			mean, spread, ok := Stats(tc.values, tc.weights, tc.circular)
+			if !ok || math.Abs(mean-tc.expectedMean) > 1e-9 || math.Abs(spread-tc.expectedSpread) > 1e-6 {
+				t.Errorf("Expected %f ± %f, got %f ± %f", tc.expectedMean, tc.expectedSpread, mean, spread)
+			}
+		})
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	issuedAt := time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		weather  models.Weather
+		label    string
+		expected float64
+	}{
+		{
+			weather:  models.Weather{Time: issuedAt, IssuedAt: issuedAt},
+			label:    "agreeing sources, no lead time",
+			expected: 1.0,
+		},
+		{
+			weather:  models.Weather{Time: issuedAt.Add(7 * 24 * time.Hour), IssuedAt: issuedAt},
+			label:    "agreeing sources, 7 days out",
+			expected: math.Exp(-1),
+		},
+		{
+			weather: models.Weather{
+				Time:              issuedAt,
+				IssuedAt:          issuedAt,
+				WaveHeightSpread:  1.0,
+				SwellPeriodSpread: 1.5,
+				WindSpeedSpread:   0.0,
+			},
+			label:    "disagreeing sources",
+			expected: 0.5,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			result := Confidence(tc.weather)
+			if math.Abs(result-tc.expected) > 1e-9 {
+				t.Errorf("Expected %f, got %f", tc.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-surf-forecast/config"
+)
+
+const (
+	defaultTimeout          = 30 * time.Second
+	defaultMaxRetries       = 3
+	defaultBaseBackoff      = time.Second
+	defaultMaxBackoff       = time.Minute
+	defaultRateLimit        = 1.0
+	defaultRateBurst        = 5
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 5 * time.Minute
+)
+
+// Client sends requests with a timeout, retries with exponential backoff on
+// network errors, 429 and 5xx responses, a client-side rate limiter and a circuit breaker
+type Client struct {
+	http        *http.Client
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	limiter     *RateLimiter
+	breaker     *CircuitBreaker
+}
+
+// New builds a client, zero values of cfg fall back to defaults
+func New(cfg config.HttpClientConfig) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultMaxRetries
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = defaultBaseBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.RateLimit <= 0 {
+		cfg.RateLimit = defaultRateLimit
+	}
+	if cfg.RateBurst <= 0 {
+		cfg.RateBurst = defaultRateBurst
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = defaultBreakerThreshold
+	}
+	if cfg.BreakerCooldown <= 0 {
+		cfg.BreakerCooldown = defaultBreakerCooldown
+	}
+
+	return &Client{
+		http:        &http.Client{Timeout: cfg.Timeout},
+		maxRetries:  cfg.MaxRetries,
+		baseBackoff: cfg.BaseBackoff,
+		maxBackoff:  cfg.MaxBackoff,
+		limiter:     NewRateLimiter(cfg.RateLimit, cfg.RateBurst),
+		breaker:     NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// Do sends req, retrying transient failures until it succeeds, req's context is done
+// or the retries are exhausted, in which case the last response or error is returned.
+// Requests must not have a body since they may be sent several times
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if err := c.breaker.Allow(); err != nil {
+			return nil, err
+		}
+		if err := c.limiter.Wait(ctx); err != nil {
+			c.breaker.Cancel()
+			return nil, err
+		}
+
+		resp, err := c.http.Do(req.Clone(ctx))
+		if ctx.Err() != nil {
+			c.breaker.Cancel()
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+
+		if !retryable(resp, err) {
+			c.breaker.Success()
+			return resp, err
+		}
+		c.breaker.Failure()
+
+		delay, ok := c.retryDelay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			log.Printf("Request to %s failed with %s, retrying in %s", req.URL.Host, resp.Status, delay)
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		} else {
+			log.Printf("Request to %s failed with %v, retrying in %s", req.URL.Host, err, delay)
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// network errors, rate limiting and server errors are worth another try
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// delay before the next attempt, false when the request must not be retried
+func (c *Client) retryDelay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if attempt >= c.maxRetries {
+		return 0, false
+	}
+
+	// exponential backoff with jitter, never more than maxBackoff
+	backoff := c.baseBackoff << attempt
+	if backoff <= 0 || backoff > c.maxBackoff {
+		backoff = c.maxBackoff
+	}
+	backoff = backoff/2 + rand.N(backoff/2+1)
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			// the server asked for a longer pause than we are willing to wait
+			if retryAfter > c.maxBackoff {
+				return 0, false
+			}
+			return max(backoff, retryAfter), true
+		}
+	}
+	return backoff, true
+}
+
+// Retry-After is either a number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(0, time.Duration(seconds)*time.Second), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now)), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("waiting to retry: %w", ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
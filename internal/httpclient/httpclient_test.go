@@ -0,0 +1,247 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go-surf-forecast/config"
+)
+
+// a test server answering the given status codes in order, then 200
+func newFailingServer(statuses []int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[call-1])
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, &calls
+}
+
+func newTestClient(cfg config.HttpClientConfig) *Client {
+	if cfg.BaseBackoff == 0 {
+		cfg.BaseBackoff = time.Millisecond
+	}
+	if cfg.RateLimit == 0 {
+		cfg.RateLimit = 1000
+	}
+	return New(cfg)
+}
+
+func get(t *testing.T, client *Client, ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		t.Fatalf("Failed to build request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return resp, err
+}
+
+func TestRetryOnServerErrors(t *testing.T) {
+	testCases := []struct {
+		statuses       []int
+		maxRetries     int
+		expectedStatus int
+		expectedCalls  int32
+	}{
+		{[]int{http.StatusServiceUnavailable, http.StatusBadGateway}, 3, http.StatusOK, 3},
+		{[]int{http.StatusInternalServerError, http.StatusInternalServerError}, 1, http.StatusInternalServerError, 2},
+		{[]int{http.StatusUnauthorized}, 3, http.StatusUnauthorized, 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing responses %v with %d retries", tc.statuses, tc.maxRetries)
+			server, calls := newFailingServer(tc.statuses, nil)
+			defer server.Close()
+
+			client := newTestClient(config.HttpClientConfig{MaxRetries: tc.maxRetries})
+			resp, err := get(t, client, context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if resp.StatusCode != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, resp.StatusCode)
+			}
+			if calls.Load() != tc.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tc.expectedCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server, calls := newFailingServer([]int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"1"}})
+	defer server.Close()
+
+	client := newTestClient(config.HttpClientConfig{})
+	begin := time.Now()
+	resp, err := get(t, client, context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls.Load())
+	}
+	if elapsed := time.Since(begin); elapsed < time.Second {
+		t.Errorf("Expected to wait at least 1s before retrying, waited %s", elapsed)
+	}
+}
+
+func TestRetryAfterTooLong(t *testing.T) {
+	server, calls := newFailingServer([]int{http.StatusTooManyRequests}, http.Header{"Retry-After": {"3600"}})
+	defer server.Close()
+
+	client := newTestClient(config.HttpClientConfig{})
+	resp, err := get(t, client, context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", resp.StatusCode)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 call, got %d", calls.Load())
+	}
+}
+
+func TestContextCancelStopsRetries(t *testing.T) {
+	server, _ := newFailingServer([]int{503, 503, 503, 503}, nil)
+	defer server.Close()
+
+	client := newTestClient(config.HttpClientConfig{BaseBackoff: time.Hour, MaxBackoff: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := get(t, client, ctx, server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server, calls := newFailingServer([]int{500, 500, 500, 500}, nil)
+	defer server.Close()
+
+	client := newTestClient(config.HttpClientConfig{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Hour})
+	for i := 0; i < 2; i++ {
+		if _, err := get(t, client, context.Background(), server.URL); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	_, err := get(t, client, context.Background(), server.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected the open breaker to stop calls at 2, got %d", calls.Load())
+	}
+}
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	testCases := []struct {
+		label string
+		// empties the rate limiter so the trial is cancelled while waiting for it
+		limited bool
+	}{
+		{"cancelled during the request", false},
+		{"cancelled waiting for the rate limiter", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			server, _ := newFailingServer([]int{500, 500}, nil)
+			defer server.Close()
+
+			client := newTestClient(config.HttpClientConfig{MaxRetries: -1, BreakerThreshold: 2, BreakerCooldown: time.Millisecond})
+			for i := 0; i < 2; i++ {
+				if _, err := get(t, client, context.Background(), server.URL); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+
+			limiter := client.limiter
+			if tc.limited {
+				client.limiter = NewRateLimiter(0.001, 1)
+				client.limiter.reserve(time.Now())
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			if tc.limited {
+				defer cancel()
+			} else {
+				cancel()
+			}
+			if _, err := get(t, client, ctx, server.URL); err == nil {
+				t.Fatalf("Expected the cancelled trial to fail")
+			}
+
+			// the server has recovered, the next trial goes through
+			client.limiter = limiter
+			resp, err := get(t, client, context.Background(), server.URL)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected 200, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, time.October, 12, 10, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, 1)
+
+	if delay := limiter.reserve(now); delay != 0 {
+		t.Errorf("Expected first request to pass, got delay %s", delay)
+	}
+	if delay := limiter.reserve(now); delay != 500*time.Millisecond {
+		t.Errorf("Expected a 500ms delay, got %s", delay)
+	}
+	if delay := limiter.reserve(now.Add(500 * time.Millisecond)); delay != 0 {
+		t.Errorf("Expected request to pass after 500ms, got delay %s", delay)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.October, 12, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"Sat, 12 Oct 2024 10:00:30 GMT", 30 * time.Second, true},
+		{"soon", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing Retry-After %q", tc.value)
+			result, ok := parseRetryAfter(tc.value, now)
+			if result != tc.expected || ok != tc.ok {
+				t.Errorf("Expected %s %t, got %s %t", tc.expected, tc.ok, result, ok)
+			}
+		})
+	}
+}
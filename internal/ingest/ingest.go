@@ -0,0 +1,147 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/provider"
+	"go-surf-forecast/internal/resample"
+	"go-surf-forecast/internal/sun"
+)
+
+// Ingester fetches forecasts from a provider and stores them, recording the outcome per spot
+type Ingester struct {
+	Provider provider.WeatherProvider
+	Weather  models.WeatherModel
+	Refresh  models.RefreshModel
+	// rows rejected by Validate
+	Quarantine models.QuarantineModel
+	// nil when no tide source is configured
+	TideProvider provider.TideProvider
+	Tide         models.TideModel
+	// longest span between two forecast hours filled by interpolation
+	MaxGap time.Duration
+}
+
+// IngestSpot fetches duration days of forecast from start for a spot and stores them
+func (i Ingester) IngestSpot(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) error {
+	err := i.ingestSpot(ctx, spot, start, duration)
+	// an exhausted quota defers the refresh, it is not a failure of the spot
+	if errors.Is(err, provider.ErrQuotaExceeded) {
+		return err
+	}
+	if err != nil {
+		if recordErr := i.Refresh.RecordFailure(spot.Id, time.Now(), err); recordErr != nil {
+			log.Printf("Could not record refresh failure for spot %d: %v", spot.Id, recordErr)
+		}
+		return err
+	}
+	return i.Refresh.RecordSuccess(spot.Id, time.Now())
+}
+
+func (i Ingester) ingestSpot(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) error {
+	forecast, err := i.Provider.GetWeatherData(ctx, spot, start, duration)
+	if err != nil {
+		return fmt.Errorf("fetching %s data for spot %d: %w", i.Provider.Name(), spot.Id, err)
+	}
+	valid, rejected := Validate(forecast.Provider, forecast.Hours)
+	if len(rejected) > 0 {
+		log.Printf("Quarantining %d of %d %s hours for spot %d, first: %s at %v",
+			len(rejected), len(forecast.Hours), forecast.Provider, spot.Id, rejected[0].Reason, rejected[0].Time)
+		if err := i.Quarantine.InsertQuarantine(rejected); err != nil {
+			return fmt.Errorf("quarantining %s data for spot %d: %w", forecast.Provider, spot.Id, err)
+		}
+	}
+	// skipped and quarantined hours are filled from the hours around them
+	forecast.Hours = resample.Fill(valid, i.MaxGap)
+	for h := range forecast.Hours {
+		forecast.Hours[h].Daylight = sun.IsDaylight(spot.Lat, spot.Long, forecast.Hours[h].Time)
+	}
+
+	run := models.ForecastRun{
+		SpotId:   spot.Id,
+		Provider: forecast.Provider,
+		IssuedAt: forecast.IssuedAt,
+	}
+	if err := i.Weather.InsertForecastRun(&run, forecast.Hours); err != nil {
+		return fmt.Errorf("storing %s data for spot %d: %w", forecast.Provider, spot.Id, err)
+	}
+	log.Printf("Stored forecast run %d (%s, %d hours) for spot %d", run.RunId, run.Provider, len(forecast.Hours), spot.Id)
+	return nil
+}
+
+// days of tides fetched at once, longer than the forecast so that the refreshes of the following
+// days find them stored: with a 7 days forecast, a spot calls its tide source every 3 days
+const tideHorizonDays = 10
+
+// IngestTides makes sure the tides of a spot between start and start+duration days are stored.
+// Tides only depend on astronomy, so nothing is fetched while the stored tides cover the period,
+// otherwise tideHorizonDays are fetched
+func (i Ingester) IngestTides(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) error {
+	if i.TideProvider == nil {
+		return nil
+	}
+
+	lastTide, err := i.Tide.GetLastTideTime(spot.Id)
+	if err != nil {
+		return fmt.Errorf("reading tides of spot %d: %w", spot.Id, err)
+	}
+	// the last extreme of a period is at most a tide cycle before its end
+	if lastTide.After(start.Add(time.Duration(duration)*24*time.Hour - 13*time.Hour)) {
+		return nil
+	}
+
+	tides, err := i.TideProvider.GetTideExtremes(ctx, spot, start, max(duration, tideHorizonDays))
+	if err != nil {
+		return fmt.Errorf("fetching %s tides for spot %d: %w", i.TideProvider.Name(), spot.Id, err)
+	}
+	if err := i.Tide.InsertTides(spot.Id, tides); err != nil {
+		return fmt.Errorf("storing %s tides for spot %d: %w", i.TideProvider.Name(), spot.Id, err)
+	}
+	log.Printf("Stored %d %s tides for spot %d", len(tides), i.TideProvider.Name(), spot.Id)
+	return nil
+}
+
+// New builds the ingester of the configured weather_data and tide_data sources. The weather source
+// defaults to file, the tide source to the weather source when it provides tides
+func New(db *sql.DB, cfg *config.Config) (Ingester, error) {
+	weatherDataSource := cfg.WeatherData.Source
+	if weatherDataSource == "" {
+		weatherDataSource = "file"
+	}
+	weatherProvider, err := provider.Get(weatherDataSource)
+	if err != nil {
+		return Ingester{}, err
+	}
+
+	ingester := Ingester{
+		Provider:   weatherProvider,
+		Weather:    models.WeatherModel{DB: db},
+		Refresh:    models.RefreshModel{DB: db},
+		Quarantine: models.QuarantineModel{DB: db},
+		Tide:       models.TideModel{DB: db},
+		MaxGap:     cfg.Interpolation.MaxGap,
+	}
+	if ingester.MaxGap <= 0 {
+		ingester.MaxGap = resample.DefaultMaxGap
+	}
+
+	if cfg.TideData.Source != "" {
+		ingester.TideProvider, err = provider.GetTideProvider(cfg.TideData.Source)
+		if err != nil {
+			return Ingester{}, err
+		}
+	} else if tideProvider, err := provider.GetTideProvider(weatherDataSource); err == nil {
+		ingester.TideProvider = tideProvider
+	} else {
+		log.Printf("No tide source configured and %s provides no tides, tides are disabled", weatherDataSource)
+	}
+
+	return ingester, nil
+}
@@ -0,0 +1,113 @@
+package ingest
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+
+	"go-surf-forecast/internal/models"
+)
+
+// check of one weather parameter: its physical range and its largest plausible change in one hour
+type check struct {
+	name     string
+	value    func(models.Weather) sql.NullFloat64
+	min, max float64
+	// 0 when spikes are not looked for
+	maxStep float64
+}
+
+var checks = []check{
+	{"wave_height", func(w models.Weather) sql.NullFloat64 { return w.WaveHeight }, 0, 25, 2},
+	{"wave_period", func(w models.Weather) sql.NullFloat64 { return w.WavePeriod }, 0, 30, 0},
+	{"wave_direction", func(w models.Weather) sql.NullFloat64 { return w.WaveDirection }, 0, 360, 0},
+	{"swell_height", func(w models.Weather) sql.NullFloat64 { return w.SwellHeight }, 0, 25, 2},
+	{"swell_period", func(w models.Weather) sql.NullFloat64 { return w.SwellPeriod }, 0, 30, 0},
+	{"swell_direction", func(w models.Weather) sql.NullFloat64 { return w.SwellDirection }, 0, 360, 0},
+	{"wind_speed", func(w models.Weather) sql.NullFloat64 { return w.WindSpeed }, 0, 60, 10},
+	{"wind_direction", func(w models.Weather) sql.NullFloat64 { return w.WindDirection }, 0, 360, 0},
+	{"current_speed", func(w models.Weather) sql.NullFloat64 { return w.CurrentSpeed }, 0, 10, 0},
+	{"sea_level", func(w models.Weather) sql.NullFloat64 { return w.SeaLevel }, -10, 10, 0},
+	{"air_temperature", func(w models.Weather) sql.NullFloat64 { return w.AirTemperature }, -40, 50, 8},
+	{"water_temperature", func(w models.Weather) sql.NullFloat64 { return w.WaterTemperature }, -2, 35, 3},
+	{"secondary_swell_height", func(w models.Weather) sql.NullFloat64 { return w.SecondarySwellHeight }, 0, 25, 2},
+	{"secondary_swell_period", func(w models.Weather) sql.NullFloat64 { return w.SecondarySwellPeriod }, 0, 30, 0},
+	{"secondary_swell_direction", func(w models.Weather) sql.NullFloat64 { return w.SecondarySwellDirection }, 0, 360, 0},
+	{"wind_wave_height", func(w models.Weather) sql.NullFloat64 { return w.WindWaveHeight }, 0, 25, 2},
+	{"wind_wave_period", func(w models.Weather) sql.NullFloat64 { return w.WindWavePeriod }, 0, 30, 0},
+	{"wind_wave_direction", func(w models.Weather) sql.NullFloat64 { return w.WindWaveDirection }, 0, 360, 0},
+	{"gust", func(w models.Weather) sql.NullFloat64 { return w.Gust }, 0, 100, 0},
+	{"precipitation", func(w models.Weather) sql.NullFloat64 { return w.Precipitation }, 0, 300, 0},
+	{"cloud_cover", func(w models.Weather) sql.NullFloat64 { return w.CloudCover }, 0, 100, 0},
+	{"visibility", func(w models.Weather) sql.NullFloat64 { return w.Visibility }, 0, 500, 0},
+	{"pressure", func(w models.Weather) sql.NullFloat64 { return w.Pressure }, 850, 1100, 0},
+}
+
+// Validate splits hourly rows, sorted by time, into the rows to store and the rows to quarantine:
+// values out of their physical range, and isolated spikes jumping away from both neighbouring
+// hours and back faster than plausible
+func Validate(provider string, rows []models.Weather) ([]models.Weather, []models.QuarantinedWeather) {
+	var valid []models.Weather
+	var rejected []models.QuarantinedWeather
+
+	for i, row := range rows {
+		var reasons []string
+		for _, c := range checks {
+			value := c.value(row)
+			if !value.Valid {
+				continue
+			}
+			if math.IsNaN(value.Float64) || value.Float64 < c.min || value.Float64 > c.max {
+				reasons = append(reasons, fmt.Sprintf("%s %g out of [%g, %g]", c.name, value.Float64, c.min, c.max))
+				continue
+			}
+			if c.maxStep > 0 && i > 0 && i < len(rows)-1 && isSpike(c, rows[i-1], row, rows[i+1]) {
+				reasons = append(reasons, fmt.Sprintf("%s spike to %g (neighbours %g, %g)",
+					c.name, value.Float64, c.value(rows[i-1]).Float64, c.value(rows[i+1]).Float64))
+			}
+		}
+
+		if len(reasons) == 0 {
+			valid = append(valid, row)
+			continue
+		}
+		rejected = append(rejected, models.QuarantinedWeather{
+			SpotId:   row.SpotId,
+			Provider: provider,
+			Time:     row.Time,
+			Reason:   strings.Join(reasons, "; "),
+			Values:   values(row),
+		})
+	}
+
+	return valid, rejected
+}
+
+// a value is a spike when it moves away from both neighbours in the same direction,
+// by more than the plausible change over the hours separating them
+func isSpike(c check, previous, row, next models.Weather) bool {
+	before, value, after := c.value(previous), c.value(row), c.value(next)
+	if !before.Valid || !after.Valid {
+		return false
+	}
+	fromBefore := value.Float64 - before.Float64
+	toAfter := value.Float64 - after.Float64
+	limitBefore := c.maxStep * math.Max(1, row.Time.Sub(previous.Time).Hours())
+	limitAfter := c.maxStep * math.Max(1, next.Time.Sub(row.Time).Hours())
+	return math.Abs(fromBefore) > limitBefore && math.Abs(toAfter) > limitAfter && (fromBefore > 0) == (toAfter > 0)
+}
+
+// values of the checked parameters of a row, nil when missing
+func values(row models.Weather) map[string]*float64 {
+	values := make(map[string]*float64, len(checks))
+	for _, c := range checks {
+		// NaN and infinite values have no JSON form, the reason keeps them
+		if value := c.value(row); value.Valid && !math.IsNaN(value.Float64) && !math.IsInf(value.Float64, 0) {
+			values[c.name] = &value.Float64
+		} else {
+			values[c.name] = nil
+		}
+	}
+	return values
+}
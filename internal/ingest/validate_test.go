@@ -0,0 +1,84 @@
+package ingest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2024, time.October, 12, 8, 0, 0, 0, time.UTC)
+	hour := func(h int, waveHeight, windSpeed, windDirection float64) models.Weather {
+		return models.Weather{
+			SpotId:        1,
+			Time:          start.Add(time.Duration(h) * time.Hour),
+			WaveHeight:    models.Float(waveHeight),
+			WindSpeed:     models.Float(windSpeed),
+			WindDirection: models.Float(windDirection),
+		}
+	}
+
+	testCases := []struct {
+		label    string
+		rows     []models.Weather
+		rejected []int
+	}{
+		{
+			label:    "plausible hours",
+			rows:     []models.Weather{hour(0, 1.0, 5, 270), hour(1, 1.2, 6, 280), hour(2, 1.1, 7, 290)},
+			rejected: nil,
+		},
+		{
+			label:    "direction out of range",
+			rows:     []models.Weather{hour(0, 1.0, 5, 270), hour(1, 1.2, 6, 400), hour(2, 1.1, 7, 290)},
+			rejected: []int{1},
+		},
+		{
+			label:    "negative height",
+			rows:     []models.Weather{hour(0, -1.0, 5, 270)},
+			rejected: []int{0},
+		},
+		{
+			label:    "not a number",
+			rows:     []models.Weather{hour(0, math.NaN(), 5, 270)},
+			rejected: []int{0},
+		},
+		{
+			label:    "wave height spike",
+			rows:     []models.Weather{hour(0, 1.0, 5, 270), hour(1, 9.0, 6, 280), hour(2, 1.1, 7, 290)},
+			rejected: []int{1},
+		},
+		{
+			label:    "rising swell is not a spike",
+			rows:     []models.Weather{hour(0, 1.0, 5, 270), hour(1, 2.5, 6, 280), hour(2, 4.0, 7, 290)},
+			rejected: nil,
+		},
+		{
+			label:    "slow change over a gap",
+			rows:     []models.Weather{hour(0, 1.0, 5, 270), hour(6, 6.0, 6, 280), hour(12, 1.0, 7, 290)},
+			rejected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			valid, rejected := Validate("file", tc.rows)
+			if len(valid)+len(rejected) != len(tc.rows) {
+				t.Fatalf("Expected %d rows, got %d valid and %d rejected", len(tc.rows), len(valid), len(rejected))
+			}
+			if len(rejected) != len(tc.rejected) {
+				t.Fatalf("Expected %d rejected rows, got %d: %+v", len(tc.rejected), len(rejected), rejected)
+			}
+			for i, index := range tc.rejected {
+				if !rejected[i].Time.Equal(tc.rows[index].Time) {
+					t.Errorf("Expected row at %v rejected, got %v", tc.rows[index].Time, rejected[i].Time)
+				}
+				if rejected[i].Reason == "" || rejected[i].Provider != "file" {
+					t.Errorf("Expected a reason and the provider, got %+v", rejected[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ForecastRun is one ingestion of a provider forecast for a spot, weather rows are versioned by run
+type ForecastRun struct {
+	RunId    int       `db:"run_id"`
+	SpotId   int       `db:"spot_id"`
+	Provider string    `db:"provider"`
+	IssuedAt time.Time `db:"issued_at"`
+}
+
+// store a new forecast run and its weather rows, run.RunId is set on success
+func (w WeatherModel) InsertForecastRun(run *ForecastRun, weatherRows []Weather) error {
+	tx, err := w.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`INSERT INTO forecast_run (spot_id, provider, issued_at)
+		VALUES ($1, $2, $3)
+		RETURNING run_id`,
+		run.SpotId, run.Provider, run.IssuedAt).Scan(&run.RunId)
+	if err != nil {
+		return err
+	}
+
+	for _, data := range weatherRows {
+		_, err := tx.Exec(`INSERT INTO weather(
+            spot_id, run_id, timestamp, air_temperature, current_speed, sea_level, swell_direction, 
+            swell_height, swell_period, water_temperature, wave_direction, wave_height, 
+            wave_period, wind_direction, wind_speed, secondary_swell_direction, secondary_swell_height, secondary_swell_period,
+            wind_wave_direction, wind_wave_height, wind_wave_period, gust, precipitation, cloud_cover, visibility, pressure,
+            wave_height_spread, swell_period_spread, wind_speed_spread, daylight, interpolated) 
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21,
+            $22, $23, $24, $25, $26, $27, $28, $29, $30, $31)`,
+			run.SpotId, run.RunId, data.Time, data.AirTemperature, data.CurrentSpeed, data.SeaLevel,
+			data.SwellDirection, data.SwellHeight, data.SwellPeriod, data.WaterTemperature,
+			data.WaveDirection, data.WaveHeight, data.WavePeriod, data.WindDirection, data.WindSpeed,
+			data.SecondarySwellDirection, data.SecondarySwellHeight, data.SecondarySwellPeriod,
+			data.WindWaveDirection, data.WindWaveHeight, data.WindWavePeriod,
+			data.Gust, data.Precipitation, data.CloudCover, data.Visibility, data.Pressure,
+			data.WaveHeightSpread, data.SwellPeriodSpread, data.WindSpeedSpread, data.Daylight, data.Interpolated)
+		if err != nil {
+			return err
+		}
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("weather_source", "run_id", "timestamp", "parameter", "source", "value"))
+	if err != nil {
+		return err
+	}
+	for _, data := range weatherRows {
+		for _, source := range data.Sources {
+			if _, err := stmt.Exec(run.RunId, data.Time, source.Parameter, source.Source, source.Value); err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// returns the last forecast runs of a spot, most recent first
+func (w WeatherModel) GetLatestForecastRuns(spotId int, limit int) ([]ForecastRun, error) {
+	rows, err := w.DB.Query(`
+        SELECT run_id, spot_id, provider, issued_at
+        FROM forecast_run
+        WHERE spot_id = $1
+        ORDER BY issued_at DESC, run_id DESC
+        LIMIT $2
+    `, spotId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []ForecastRun
+	for rows.Next() {
+		var run ForecastRun
+		if err := rows.Scan(&run.RunId, &run.SpotId, &run.Provider, &run.IssuedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// returns a forecast run, sql.ErrNoRows if it does not exist
+func (w WeatherModel) GetForecastRun(runId int) (ForecastRun, error) {
+	var run ForecastRun
+	err := w.DB.QueryRow(`
+        SELECT run_id, spot_id, provider, issued_at
+        FROM forecast_run
+        WHERE run_id = $1
+    `, runId).Scan(&run.RunId, &run.SpotId, &run.Provider, &run.IssuedAt)
+	return run, err
+}
+
+// returns the daylight weather rows stored by a forecast run
+func (w WeatherModel) GetForecastRunWeather(runId int) ([]Weather, error) {
+	rows, err := w.DB.Query(`
+        SELECT `+weatherColumns+`
+        FROM weather w
+        JOIN forecast_run r ON r.run_id = w.run_id
+        WHERE w.run_id = $1 AND `+daylightCondition+`
+        ORDER BY w.timestamp
+    `, runId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanWeatherRows(rows)
+}
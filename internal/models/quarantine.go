@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// QuarantinedWeather is a weather row rejected on ingestion, kept with the reason of the rejection
+type QuarantinedWeather struct {
+	SpotId   int
+	Provider string
+	Time     time.Time
+	Reason   string
+	// parameter values of the row, nil when missing
+	Values map[string]*float64
+}
+
+type QuarantineModel struct {
+	DB *sql.DB
+}
+
+// store rejected weather rows
+func (m QuarantineModel) InsertQuarantine(rows []QuarantinedWeather) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	for _, row := range rows {
+		values, err := json.Marshal(row.Values)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO weather_quarantine (spot_id, provider, timestamp, reason, data, quarantined_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			row.SpotId, row.Provider, row.Time, row.Reason, values, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Quota is the API consumption of a provider for one UTC day
+type Quota struct {
+	Provider     string    `db:"provider"`
+	Day          time.Time `db:"day"`
+	RequestCount int       `db:"request_count"`
+	DailyQuota   int       `db:"daily_quota"`
+	UpdatedAt    time.Time `db:"updated_at"`
+}
+
+func (q Quota) Remaining() int {
+	return max(0, q.DailyQuota-q.RequestCount)
+}
+
+type QuotaModel struct {
+	DB *sql.DB
+}
+
+// returns the quota of a provider for a day, sql.ErrNoRows if nothing was recorded that day
+func (m QuotaModel) GetQuota(provider string, day time.Time) (Quota, error) {
+	quota := Quota{Provider: provider}
+	err := m.DB.QueryRow(`
+        SELECT day, request_count, daily_quota, updated_at
+        FROM provider_quota
+        WHERE provider = $1 AND day = $2
+    `, provider, day.UTC().Format(time.DateOnly)).Scan(&quota.Day, &quota.RequestCount, &quota.DailyQuota, &quota.UpdatedAt)
+	return quota, err
+}
+
+func (m QuotaModel) SaveQuota(quota Quota) error {
+	_, err := m.DB.Exec(`INSERT INTO provider_quota (provider, day, request_count, daily_quota, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, day) DO UPDATE SET
+			request_count = GREATEST(provider_quota.request_count, EXCLUDED.request_count),
+			daily_quota = EXCLUDED.daily_quota,
+			updated_at = EXCLUDED.updated_at`,
+		quota.Provider, quota.Day.UTC().Format(time.DateOnly), quota.RequestCount, quota.DailyQuota, quota.UpdatedAt)
+	return err
+}
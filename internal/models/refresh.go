@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SpotRefresh struct {
+	SpotId              int            `db:"spot_id"`
+	LastAttempt         sql.NullTime   `db:"last_attempt"`
+	LastSuccess         sql.NullTime   `db:"last_success"`
+	LastError           sql.NullString `db:"last_error"`
+	ConsecutiveFailures int            `db:"consecutive_failures"`
+}
+
+type RefreshModel struct {
+	DB *sql.DB
+}
+
+// returns the refresh state of a spot, a zero state if the spot was never refreshed
+func (m RefreshModel) GetSpotRefresh(spotId int) (SpotRefresh, error) {
+	refresh := SpotRefresh{SpotId: spotId}
+	err := m.DB.QueryRow(`
+        SELECT last_attempt, last_success, last_error, consecutive_failures
+        FROM spot_refresh
+        WHERE spot_id = $1
+    `, spotId).Scan(&refresh.LastAttempt, &refresh.LastSuccess, &refresh.LastError, &refresh.ConsecutiveFailures)
+	if errors.Is(err, sql.ErrNoRows) {
+		return refresh, nil
+	}
+	return refresh, err
+}
+
+func (m RefreshModel) RecordSuccess(spotId int, at time.Time) error {
+	_, err := m.DB.Exec(`INSERT INTO spot_refresh (spot_id, last_attempt, last_success, last_error, consecutive_failures)
+		VALUES ($1, $2, $2, NULL, 0)
+		ON CONFLICT (spot_id) DO UPDATE SET
+			last_attempt = EXCLUDED.last_attempt,
+			last_success = EXCLUDED.last_success,
+			last_error = NULL,
+			consecutive_failures = 0`,
+		spotId, at)
+	return err
+}
+
+func (m RefreshModel) RecordFailure(spotId int, at time.Time, cause error) error {
+	_, err := m.DB.Exec(`INSERT INTO spot_refresh (spot_id, last_attempt, last_error, consecutive_failures)
+		VALUES ($1, $2, $3, 1)
+		ON CONFLICT (spot_id) DO UPDATE SET
+			last_attempt = EXCLUDED.last_attempt,
+			last_error = EXCLUDED.last_error,
+			consecutive_failures = spot_refresh.consecutive_failures + 1`,
+		spotId, at, cause.Error())
+	return err
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Tide is a high or low tide of a spot
+type Tide struct {
+	SpotId int       `db:"spot_id"`
+	Time   time.Time `db:"timestamp"`
+	Height float64   `db:"height"`
+	Type   string    `db:"type"`
+	Source string    `db:"source"`
+}
+
+// TideState is the tide at a given time, interpolated between the surrounding extremes
+type TideState struct {
+	Height float64
+	Level  float64 // 0 at low tide, 1 at high tide
+	Rising bool
+}
+
+// Stage returns low, mid or high by thirds of the tide range
+func (s TideState) Stage() string {
+	if s.Level < 1.0/3 {
+		return "low"
+	} else if s.Level < 2.0/3 {
+		return "mid"
+	}
+	return "high"
+}
+
+type TideModel struct {
+	DB *sql.DB
+}
+
+// replace the stored tides of a spot between the first and last given tides
+func (m TideModel) InsertTides(spotId int, tides []Tide) error {
+	if len(tides) == 0 {
+		return nil
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM tide WHERE spot_id = $1 AND timestamp BETWEEN $2 AND $3`,
+		spotId, tides[0].Time, tides[len(tides)-1].Time)
+	if err != nil {
+		return err
+	}
+
+	for _, tide := range tides {
+		_, err := tx.Exec(`INSERT INTO tide (spot_id, timestamp, height, type, source)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (spot_id, timestamp) DO UPDATE SET
+				height = EXCLUDED.height,
+				type = EXCLUDED.type,
+				source = EXCLUDED.source`,
+			spotId, tide.Time, tide.Height, tide.Type, tide.Source)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// returns the tides of a spot between start and end, ordered by time
+func (m TideModel) GetTidesFromDb(spotId int, start time.Time, end time.Time) ([]Tide, error) {
+	rows, err := m.DB.Query(`
+        SELECT spot_id, timestamp, height, type, source
+        FROM tide
+        WHERE spot_id = $1 AND timestamp BETWEEN $2 AND $3
+        ORDER BY timestamp
+    `, spotId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tides []Tide
+	for rows.Next() {
+		var tide Tide
+		if err := rows.Scan(&tide.SpotId, &tide.Time, &tide.Height, &tide.Type, &tide.Source); err != nil {
+			return nil, err
+		}
+		tides = append(tides, tide)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tides, nil
+}
+
+// returns the time of the last stored tide of a spot, zero if none
+func (m TideModel) GetLastTideTime(spotId int) (time.Time, error) {
+	var last sql.NullTime
+	err := m.DB.QueryRow(`SELECT MAX(timestamp) FROM tide WHERE spot_id = $1`, spotId).Scan(&last)
+	return last.Time, err
+}
@@ -5,44 +5,103 @@ import (
 	"time"
 )
 
+// Weather is the forecast of a spot for one hour, parameters not given by the provider are NULL
 type Weather struct {
-	SpotId           int       `db:"spot_id"`
-	Time             time.Time `db:"timestamp"`
-	AirTemperature   float64   `db:"air_temperature"`
-	CurrentSpeed     float64   `db:"current_speed"`
-	SeaLevel         float64   `db:"sea_level"`
-	SwellDirection   float64   `db:"swell_direction"`
-	SwellHeight      float64   `db:"swell_height"`
-	SwellPeriod      float64   `db:"swell_period"`
-	WaterTemperature float64   `db:"water_temperature"`
-	WaveDirection    float64   `db:"wave_direction"`
-	WaveHeight       float64   `db:"wave_height"`
-	WavePeriod       float64   `db:"wave_period"`
-	WindDirection    float64   `db:"wind_direction"`
-	WindSpeed        float64   `db:"wind_speed"`
+	SpotId           int             `db:"spot_id"`
+	RunId            int             `db:"run_id"`
+	Time             time.Time       `db:"timestamp"`
+	AirTemperature   sql.NullFloat64 `db:"air_temperature"`
+	CurrentSpeed     sql.NullFloat64 `db:"current_speed"`
+	SeaLevel         sql.NullFloat64 `db:"sea_level"`
+	SwellDirection   sql.NullFloat64 `db:"swell_direction"`
+	SwellHeight      sql.NullFloat64 `db:"swell_height"`
+	SwellPeriod      sql.NullFloat64 `db:"swell_period"`
+	WaterTemperature sql.NullFloat64 `db:"water_temperature"`
+	WaveDirection    sql.NullFloat64 `db:"wave_direction"`
+	WaveHeight       sql.NullFloat64 `db:"wave_height"`
+	WavePeriod       sql.NullFloat64 `db:"wave_period"`
+	WindDirection    sql.NullFloat64 `db:"wind_direction"`
+	WindSpeed        sql.NullFloat64 `db:"wind_speed"`
+	// swell partitions: the swell above is the dominant one, a secondary swell may cross it
+	// and the wind waves are the local sea raised by the wind
+	SecondarySwellDirection sql.NullFloat64 `db:"secondary_swell_direction"`
+	SecondarySwellHeight    sql.NullFloat64 `db:"secondary_swell_height"`
+	SecondarySwellPeriod    sql.NullFloat64 `db:"secondary_swell_period"`
+	WindWaveDirection       sql.NullFloat64 `db:"wind_wave_direction"`
+	WindWaveHeight          sql.NullFloat64 `db:"wind_wave_height"`
+	WindWavePeriod          sql.NullFloat64 `db:"wind_wave_period"`
+	// atmosphere, shown by the conditions endpoint, gusts and rain also lower the score
+	Gust          sql.NullFloat64 `db:"gust"`          // m/s
+	Precipitation sql.NullFloat64 `db:"precipitation"` // mm/h
+	CloudCover    sql.NullFloat64 `db:"cloud_cover"`   // %
+	Visibility    sql.NullFloat64 `db:"visibility"`    // km
+	Pressure      sql.NullFloat64 `db:"pressure"`      // hPa
+	// between the civil dawn and dusk of the spot
+	Daylight bool `db:"daylight"`
+	// interpolated between the forecast hours around it, the provider gave no value for the hour
+	Interpolated bool `db:"interpolated"`
+	// standard deviation between the sources of the provider, 0 with a single source
+	WaveHeightSpread  float64 `db:"wave_height_spread"`
+	SwellPeriodSpread float64 `db:"swell_period_spread"`
+	WindSpeedSpread   float64 `db:"wind_speed_spread"`
+	// issue time of the forecast run the row comes from
+	IssuedAt time.Time `db:"issued_at"`
+	// every model value behind the row, only set on ingestion
+	Sources []SourceValue `db:"-"`
+	// tide at the hour, set from the tide table when the spot has tides
+	Tide *TideState `db:"-"`
+}
+
+// Float returns a known weather value
+func Float(value float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: value, Valid: true}
+}
+
+// SourceValue is the value of one parameter given by one source (model) of a provider
+type SourceValue struct {
+	Parameter string  `db:"parameter"`
+	Source    string  `db:"source"`
+	Value     float64 `db:"value"`
 }
 
 type WeatherModel struct {
 	DB *sql.DB
 }
 
-func (w WeatherModel) GetWeatherDataFromDb(spotId int, start time.Time, duration int) ([]Weather, error) {
+// columns of the weather table joined with forecast_run, in the order expected by scanWeatherRows
+const weatherColumns = `w.spot_id, w.run_id, w.timestamp, w.air_temperature, w.current_speed, w.sea_level, w.swell_direction, w.swell_height, w.swell_period, w.water_temperature, w.wave_direction, w.wave_height, w.wave_period, w.wind_direction, w.wind_speed, w.secondary_swell_direction, w.secondary_swell_height, w.secondary_swell_period, w.wind_wave_direction, w.wind_wave_height, w.wind_wave_period, w.gust, w.precipitation, w.cloud_cover, w.visibility, w.pressure, w.wave_height_spread, w.swell_period_spread, w.wind_speed_spread, w.interpolated, r.issued_at`
+
+// hours of the day returned by the API, flagged on ingestion. Rows stored
+// before the flag existed keep the former 6-22h UTC window
+const daylightCondition = `COALESCE(w.daylight, EXTRACT(HOUR FROM w.timestamp AT TIME ZONE 'UTC') > 5 AND EXTRACT(HOUR FROM w.timestamp AT TIME ZONE 'UTC') <= 22)`
+
+// returns hourly weather for a spot in [start, end), each hour taken from the latest forecast run
+// covering it. A non zero asOf ignores the runs issued after it, to replay a past forecast
+func (w WeatherModel) GetWeatherDataFromDb(spotId int, start time.Time, end time.Time, asOf time.Time) ([]Weather, error) {
 	rows, err := w.DB.Query(`
-        SELECT spot_id, timestamp, air_temperature, current_speed, sea_level, swell_direction, swell_height, swell_period, water_temperature, wave_direction, wave_height, wave_period, wind_direction, wind_speed
-        FROM weather
-        WHERE spot_id = $1 AND timestamp BETWEEN $2 AND $3 AND EXTRACT(HOUR FROM timestamp) > 5 AND EXTRACT(HOUR FROM timestamp) <= 22
-    `, spotId, start, start.Add(time.Duration(duration)*24*time.Hour))
+        SELECT DISTINCT ON (w.timestamp) `+weatherColumns+`
+        FROM weather w
+        JOIN forecast_run r ON r.run_id = w.run_id
+        WHERE w.spot_id = $1 AND w.timestamp >= $2 AND w.timestamp < $3 AND `+daylightCondition+`
+            AND ($4::timestamptz IS NULL OR r.issued_at <= $4)
+        ORDER BY w.timestamp, r.issued_at DESC, r.run_id DESC
+    `, spotId, start, end, sql.NullTime{Time: asOf, Valid: !asOf.IsZero()})
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return scanWeatherRows(rows)
+}
+
+func scanWeatherRows(rows *sql.Rows) ([]Weather, error) {
 	var weatherRows []Weather
 
 	for rows.Next() {
 		var weather Weather
 		err := rows.Scan(
 			&weather.SpotId,
+			&weather.RunId,
 			&weather.Time,
 			&weather.AirTemperature,
 			&weather.CurrentSpeed,
@@ -56,13 +115,29 @@ func (w WeatherModel) GetWeatherDataFromDb(spotId int, start time.Time, duration
 			&weather.WavePeriod,
 			&weather.WindDirection,
 			&weather.WindSpeed,
+			&weather.SecondarySwellDirection,
+			&weather.SecondarySwellHeight,
+			&weather.SecondarySwellPeriod,
+			&weather.WindWaveDirection,
+			&weather.WindWaveHeight,
+			&weather.WindWavePeriod,
+			&weather.Gust,
+			&weather.Precipitation,
+			&weather.CloudCover,
+			&weather.Visibility,
+			&weather.Pressure,
+			&weather.WaveHeightSpread,
+			&weather.SwellPeriodSpread,
+			&weather.WindSpeedSpread,
+			&weather.Interpolated,
+			&weather.IssuedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		weatherRows = append(weatherRows, weather)
 	}
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,251 @@
+package openmeteo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/httpclient"
+)
+
+const (
+	marineParams   = "wave_height,wave_direction,wave_period,swell_wave_height,swell_wave_direction,swell_wave_period,secondary_swell_wave_height,secondary_swell_wave_direction,secondary_swell_wave_period,wind_wave_height,wind_wave_direction,wind_wave_period,sea_surface_temperature,ocean_current_velocity,sea_level_height_msl"
+	forecastParams = "temperature_2m,wind_speed_10m,wind_direction_10m,wind_gusts_10m,precipitation,cloud_cover,visibility,pressure_msl"
+)
+
+type MarineApiResponse struct {
+	Latitude  float64      `json:"latitude"`
+	Longitude float64      `json:"longitude"`
+	Hourly    MarineHourly `json:"hourly"`
+}
+
+type MarineHourly struct {
+	Time                        []int64    `json:"time"`
+	WaveHeight                  []*float64 `json:"wave_height"`
+	WaveDirection               []*float64 `json:"wave_direction"`
+	WavePeriod                  []*float64 `json:"wave_period"`
+	SwellWaveHeight             []*float64 `json:"swell_wave_height"`
+	SwellWaveDirection          []*float64 `json:"swell_wave_direction"`
+	SwellWavePeriod             []*float64 `json:"swell_wave_period"`
+	SecondarySwellWaveHeight    []*float64 `json:"secondary_swell_wave_height"`
+	SecondarySwellWaveDirection []*float64 `json:"secondary_swell_wave_direction"`
+	SecondarySwellWavePeriod    []*float64 `json:"secondary_swell_wave_period"`
+	WindWaveHeight              []*float64 `json:"wind_wave_height"`
+	WindWaveDirection           []*float64 `json:"wind_wave_direction"`
+	WindWavePeriod              []*float64 `json:"wind_wave_period"`
+	SeaSurfaceTemperature       []*float64 `json:"sea_surface_temperature"`
+	OceanCurrentVelocity        []*float64 `json:"ocean_current_velocity"`
+	SeaLevelHeightMsl           []*float64 `json:"sea_level_height_msl"`
+}
+
+type ForecastApiResponse struct {
+	Latitude  float64        `json:"latitude"`
+	Longitude float64        `json:"longitude"`
+	Hourly    ForecastHourly `json:"hourly"`
+}
+
+type ForecastHourly struct {
+	Time             []int64    `json:"time"`
+	Temperature2m    []*float64 `json:"temperature_2m"`
+	WindSpeed10m     []*float64 `json:"wind_speed_10m"`
+	WindDirection10m []*float64 `json:"wind_direction_10m"`
+	WindGusts10m     []*float64 `json:"wind_gusts_10m"`
+	Precipitation    []*float64 `json:"precipitation"`
+	CloudCover       []*float64 `json:"cloud_cover"`
+	Visibility       []*float64 `json:"visibility"`
+	PressureMsl      []*float64 `json:"pressure_msl"`
+}
+
+// Hour merges the marine and forecast responses for one timestamp, in Stormglass units.
+// Values open-meteo does not give (null) are not valid
+type Hour struct {
+	Time                    time.Time
+	AirTemperature          sql.NullFloat64
+	CurrentSpeed            sql.NullFloat64
+	SeaLevel                sql.NullFloat64
+	SwellDirection          sql.NullFloat64
+	SwellHeight             sql.NullFloat64
+	SwellPeriod             sql.NullFloat64
+	WaterTemperature        sql.NullFloat64
+	WaveDirection           sql.NullFloat64
+	WaveHeight              sql.NullFloat64
+	WavePeriod              sql.NullFloat64
+	WindDirection           sql.NullFloat64
+	WindSpeed               sql.NullFloat64
+	SecondarySwellDirection sql.NullFloat64
+	SecondarySwellHeight    sql.NullFloat64
+	SecondarySwellPeriod    sql.NullFloat64
+	WindWaveDirection       sql.NullFloat64
+	WindWaveHeight          sql.NullFloat64
+	WindWavePeriod          sql.NullFloat64
+	Gust                    sql.NullFloat64
+	Precipitation           sql.NullFloat64
+	CloudCover              sql.NullFloat64
+	Visibility              sql.NullFloat64
+	Pressure                sql.NullFloat64
+}
+
+var (
+	client     *httpclient.Client
+	clientOnce sync.Once
+)
+
+// the resilient client shared by every open-meteo call, built from the loaded config
+func httpClient() *httpclient.Client {
+	clientOnce.Do(func() {
+		client = httpclient.New(config.GetConfig().OpenMeteo.Http)
+	})
+	return client
+}
+
+// call an open-meteo endpoint and decode the JSON response into v
+func getFromApi(ctx context.Context, baseUrl string, path string, params url.Values, v any) error {
+	endpoint, err := url.Parse(baseUrl)
+	if err != nil {
+		return err
+	}
+	endpoint.Path += path
+	endpoint.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get data: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+func baseParams(spot config.SpotConfig, start time.Time, duration int) url.Values {
+	end := start.Add(time.Duration(duration) * 24 * time.Hour)
+	params := url.Values{}
+	params.Add("latitude", fmt.Sprintf("%f", spot.Lat))
+	params.Add("longitude", fmt.Sprintf("%f", spot.Long))
+	params.Add("start_date", start.UTC().Format(time.DateOnly))
+	params.Add("end_date", end.UTC().Format(time.DateOnly))
+	params.Add("timeformat", "unixtime")
+	params.Add("timezone", "GMT")
+	return params
+}
+
+// call open-meteo marine endpoint v1/marine
+func GetMarineDataFromApi(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*MarineApiResponse, error) {
+	params := baseParams(spot, start, duration)
+	params.Add("hourly", marineParams)
+	params.Add("cell_selection", "sea")
+
+	var marineApiResponse MarineApiResponse
+	if err := getFromApi(ctx, config.GetConfig().OpenMeteo.MarineUrl, "/marine", params, &marineApiResponse); err != nil {
+		return nil, err
+	}
+	return &marineApiResponse, nil
+}
+
+// call open-meteo forecast endpoint v1/forecast
+func GetForecastDataFromApi(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*ForecastApiResponse, error) {
+	params := baseParams(spot, start, duration)
+	params.Add("hourly", forecastParams)
+	params.Add("wind_speed_unit", "ms")
+
+	var forecastApiResponse ForecastApiResponse
+	if err := getFromApi(ctx, config.GetConfig().OpenMeteo.ForecastUrl, "/forecast", params, &forecastApiResponse); err != nil {
+		return nil, err
+	}
+	return &forecastApiResponse, nil
+}
+
+// value at index i, not valid when it is null or the series is shorter than the time axis
+func at(series []*float64, i int) sql.NullFloat64 {
+	if i < len(series) && series[i] != nil {
+		return sql.NullFloat64{Float64: *series[i], Valid: true}
+	}
+	return sql.NullFloat64{}
+}
+
+// call the marine and forecast endpoints and merge them hour by hour
+func GetOpenMeteoWeatherDataFromApi(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) ([]Hour, error) {
+	log.Default().Printf("Calling open-meteo API for spot %d", spot.Id)
+
+	marine, err := GetMarineDataFromApi(ctx, spot, start, duration)
+	if err != nil {
+		return nil, err
+	}
+	forecast, err := GetForecastDataFromApi(ctx, spot, start, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	forecastIndex := make(map[int64]int, len(forecast.Hourly.Time))
+	for i, t := range forecast.Hourly.Time {
+		forecastIndex[t] = i
+	}
+
+	end := start.Add(time.Duration(duration) * 24 * time.Hour)
+	var hours []Hour
+	for i, t := range marine.Hourly.Time {
+		hourTime := time.Unix(t, 0).UTC()
+		if hourTime.Before(start) || !hourTime.Before(end) {
+			continue
+		}
+
+		hour := Hour{
+			Time:                    hourTime,
+			SeaLevel:                at(marine.Hourly.SeaLevelHeightMsl, i),
+			SwellDirection:          at(marine.Hourly.SwellWaveDirection, i),
+			SwellHeight:             at(marine.Hourly.SwellWaveHeight, i),
+			SwellPeriod:             at(marine.Hourly.SwellWavePeriod, i),
+			WaterTemperature:        at(marine.Hourly.SeaSurfaceTemperature, i),
+			WaveDirection:           at(marine.Hourly.WaveDirection, i),
+			WaveHeight:              at(marine.Hourly.WaveHeight, i),
+			WavePeriod:              at(marine.Hourly.WavePeriod, i),
+			SecondarySwellDirection: at(marine.Hourly.SecondarySwellWaveDirection, i),
+			SecondarySwellHeight:    at(marine.Hourly.SecondarySwellWaveHeight, i),
+			SecondarySwellPeriod:    at(marine.Hourly.SecondarySwellWavePeriod, i),
+			WindWaveDirection:       at(marine.Hourly.WindWaveDirection, i),
+			WindWaveHeight:          at(marine.Hourly.WindWaveHeight, i),
+			WindWavePeriod:          at(marine.Hourly.WindWavePeriod, i),
+		}
+		// open-meteo returns current velocity in km/h, stormglass in m/s
+		if currentSpeed := at(marine.Hourly.OceanCurrentVelocity, i); currentSpeed.Valid {
+			hour.CurrentSpeed = sql.NullFloat64{Float64: currentSpeed.Float64 / 3.6, Valid: true}
+		}
+		if j, ok := forecastIndex[t]; ok {
+			hour.AirTemperature = at(forecast.Hourly.Temperature2m, j)
+			hour.WindSpeed = at(forecast.Hourly.WindSpeed10m, j)
+			hour.WindDirection = at(forecast.Hourly.WindDirection10m, j)
+			hour.Gust = at(forecast.Hourly.WindGusts10m, j)
+			hour.Precipitation = at(forecast.Hourly.Precipitation, j)
+			hour.CloudCover = at(forecast.Hourly.CloudCover, j)
+			hour.Pressure = at(forecast.Hourly.PressureMsl, j)
+			// open-meteo returns visibility in m, stormglass in km
+			if visibility := at(forecast.Hourly.Visibility, j); visibility.Valid {
+				hour.Visibility = sql.NullFloat64{Float64: visibility.Float64 / 1000, Valid: true}
+			}
+		}
+		hours = append(hours, hour)
+	}
+
+	return hours, nil
+}
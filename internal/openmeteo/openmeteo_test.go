@@ -0,0 +1,97 @@
+package openmeteo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/test"
+)
+
+func TestGetOpenMeteoWeatherDataFromApi(t *testing.T) {
+
+	spot := config.SpotConfig{
+		Id:   1,
+		Lat:  37.7749,
+		Long: -122.4194,
+	}
+
+	start := time.Date(2023, time.October, 1, 0, 0, 0, 0, time.UTC)
+	duration := 1
+
+	mockMarineResponse, err := test.TestData.ReadFile("data/mock-open-meteo-marine.json")
+	if err != nil {
+		t.Fatalf("Failed to read mock marine response file: %v", err)
+	}
+	mockForecastResponse, err := test.TestData.ReadFile("data/mock-open-meteo-forecast.json")
+	if err != nil {
+		t.Fatalf("Failed to read mock forecast response file: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/marine", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("start_date") != "2023-10-01" {
+			t.Errorf("Expected start_date 2023-10-01, got %s", r.URL.Query().Get("start_date"))
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(mockMarineResponse)
+	})
+	mux.HandleFunc("/forecast", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wind_speed_unit") != "ms" {
+			t.Errorf("Expected wind speed in m/s, got %s", r.URL.Query().Get("wind_speed_unit"))
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(mockForecastResponse)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config.SetConfig(&config.Config{
+		OpenMeteo: config.OpenMeteoConfig{
+			MarineUrl:   server.URL,
+			ForecastUrl: server.URL,
+		},
+	})
+
+	hours, err := GetOpenMeteoWeatherDataFromApi(context.Background(), spot, start, duration)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(hours) != 2 {
+		t.Fatalf("Expected 2 hours, got %d", len(hours))
+	}
+	if !hours[0].Time.Equal(start) {
+		t.Errorf("Expected time %v, got %v", start, hours[0].Time)
+	}
+	if hours[0].WaveHeight.Float64 != 2.0 {
+		t.Errorf("Expected wave height 2.0, got %f", hours[0].WaveHeight.Float64)
+	}
+	if hours[0].AirTemperature.Float64 != 15.0 {
+		t.Errorf("Expected air temperature 15.0, got %f", hours[0].AirTemperature.Float64)
+	}
+	if hours[1].WindSpeed.Float64 != 5.5 {
+		t.Errorf("Expected wind speed 5.5, got %f", hours[1].WindSpeed.Float64)
+	}
+	if hours[0].CurrentSpeed.Float64 != 1.0 {
+		t.Errorf("Expected current speed 1.0 m/s, got %f", hours[0].CurrentSpeed.Float64)
+	}
+	if hours[0].SecondarySwellPeriod.Float64 != 14.0 {
+		t.Errorf("Expected secondary swell period 14.0, got %f", hours[0].SecondarySwellPeriod.Float64)
+	}
+	if hours[1].WindWaveHeight.Float64 != 0.9 {
+		t.Errorf("Expected wind wave height 0.9, got %f", hours[1].WindWaveHeight.Float64)
+	}
+	if hours[1].Gust.Float64 != 9.5 {
+		t.Errorf("Expected gust 9.5, got %f", hours[1].Gust.Float64)
+	}
+	if hours[1].Visibility.Float64 != 12.0 {
+		t.Errorf("Expected visibility 12 km, got %f", hours[1].Visibility.Float64)
+	}
+	if hours[1].SeaLevel.Valid {
+		t.Errorf("Expected a missing sea level, got %f", hours[1].SeaLevel.Float64)
+	}
+}
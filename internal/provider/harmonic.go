@@ -0,0 +1,47 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/tide"
+)
+
+// harmonicProvider predicts the tides of a spot from its harmonic constituents, without any API call
+type harmonicProvider struct{}
+
+func init() {
+	RegisterTideProvider(harmonicProvider{})
+}
+
+func (harmonicProvider) Name() string {
+	return "harmonic"
+}
+
+func (p harmonicProvider) GetTideExtremes(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) ([]models.Tide, error) {
+	model, err := tide.NewModel(spot.Tide)
+	if err != nil {
+		return nil, fmt.Errorf("spot %d: %w", spot.Id, err)
+	}
+
+	end := start.Add(time.Duration(duration) * 24 * time.Hour)
+	return extremesToTides(spot, p.Name(), model.Extremes(start, end)), nil
+}
+
+// map predicted extremes to the tides of a spot
+func extremesToTides(spot config.SpotConfig, source string, extremes []tide.Extreme) []models.Tide {
+	tides := make([]models.Tide, 0, len(extremes))
+	for _, extreme := range extremes {
+		tides = append(tides, models.Tide{
+			SpotId: spot.Id,
+			Time:   extreme.Time,
+			Height: extreme.Height,
+			Type:   extreme.Type,
+			Source: source,
+		})
+	}
+	return tides
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/openmeteo"
+)
+
+// openMeteoProvider calls the Open-Meteo marine and forecast APIs
+type openMeteoProvider struct{}
+
+func init() {
+	Register(openMeteoProvider{})
+}
+
+func (openMeteoProvider) Name() string {
+	return "openmeteo"
+}
+
+func (p openMeteoProvider) GetWeatherData(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*Forecast, error) {
+	hours, err := openmeteo.GetOpenMeteoWeatherDataFromApi(ctx, spot, start, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	weatherRows := make([]models.Weather, 0, len(hours))
+	for _, hour := range hours {
+		weatherRows = append(weatherRows, models.Weather{
+			SpotId:                  spot.Id,
+			Time:                    hour.Time,
+			AirTemperature:          hour.AirTemperature,
+			CurrentSpeed:            hour.CurrentSpeed,
+			SeaLevel:                hour.SeaLevel,
+			SwellDirection:          hour.SwellDirection,
+			SwellHeight:             hour.SwellHeight,
+			SwellPeriod:             hour.SwellPeriod,
+			WaterTemperature:        hour.WaterTemperature,
+			WaveDirection:           hour.WaveDirection,
+			WaveHeight:              hour.WaveHeight,
+			WavePeriod:              hour.WavePeriod,
+			WindDirection:           hour.WindDirection,
+			WindSpeed:               hour.WindSpeed,
+			SecondarySwellDirection: hour.SecondarySwellDirection,
+			SecondarySwellHeight:    hour.SecondarySwellHeight,
+			SecondarySwellPeriod:    hour.SecondarySwellPeriod,
+			WindWaveDirection:       hour.WindWaveDirection,
+			WindWaveHeight:          hour.WindWaveHeight,
+			WindWavePeriod:          hour.WindWavePeriod,
+			Gust:                    hour.Gust,
+			Precipitation:           hour.Precipitation,
+			CloudCover:              hour.CloudCover,
+			Visibility:              hour.Visibility,
+			Pressure:                hour.Pressure,
+		})
+	}
+	return &Forecast{Provider: p.Name(), IssuedAt: time.Now().UTC(), Hours: weatherRows}, nil
+}
@@ -0,0 +1,104 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+)
+
+// Forecast is the provider-neutral result of a fetch: hourly rows ready to be stored
+type Forecast struct {
+	Provider string
+	IssuedAt time.Time
+	Hours    []models.Weather
+}
+
+// ErrQuotaExceeded is wrapped by providers refusing a request because the daily API quota is used,
+// such requests should be deferred until the quota resets rather than retried
+var ErrQuotaExceeded = errors.New("provider daily quota exceeded")
+
+// WeatherProvider fetches hourly marine data for a spot over [start, start+duration days)
+type WeatherProvider interface {
+	Name() string
+	GetWeatherData(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*Forecast, error)
+}
+
+// TideProvider fetches the high and low tides of a spot over [start, start+duration days)
+type TideProvider interface {
+	Name() string
+	GetTideExtremes(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) ([]models.Tide, error)
+}
+
+var (
+	providers     = make(map[string]WeatherProvider)
+	tideProviders = make(map[string]TideProvider)
+	mu            sync.RWMutex
+)
+
+// Register makes a provider available by name, it is meant to be called from init()
+func Register(p WeatherProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := providers[p.Name()]; exists {
+		panic(fmt.Sprintf("weather provider %q registered twice", p.Name()))
+	}
+	providers[p.Name()] = p
+}
+
+// Get returns the provider registered under name
+func Get(name string) (WeatherProvider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown weather provider %q (available: %v)", name, names())
+	}
+	return p, nil
+}
+
+// Names returns the sorted list of registered providers
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return names()
+}
+
+// RegisterTideProvider makes a tide provider available by name, it is meant to be called from init()
+func RegisterTideProvider(p TideProvider) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := tideProviders[p.Name()]; exists {
+		panic(fmt.Sprintf("tide provider %q registered twice", p.Name()))
+	}
+	tideProviders[p.Name()] = p
+}
+
+// GetTideProvider returns the tide provider registered under name
+func GetTideProvider(name string) (TideProvider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := tideProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tide provider %q (available: %v)", name, sortedNames(tideProviders))
+	}
+	return p, nil
+}
+
+func names() []string {
+	return sortedNames(providers)
+}
+
+func sortedNames[T any](registry map[string]T) []string {
+	list := make([]string, 0, len(registry))
+	for name := range registry {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
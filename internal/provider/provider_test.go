@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go-surf-forecast/config"
+)
+
+func TestGetUnknownProvider(t *testing.T) {
+	_, err := Get("unknown")
+	if err == nil {
+		t.Errorf("Expected an error for an unknown provider")
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	config.SetConfig(&config.Config{})
+
+	p, err := Get("file")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	spot := config.SpotConfig{Id: 1}
+	forecast, err := p.GetWeatherData(context.Background(), spot, time.Now(), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if forecast.Provider != "file" {
+		t.Errorf("Expected provider file, got %s", forecast.Provider)
+	}
+	if len(forecast.Hours) == 0 {
+		t.Fatalf("Expected weather rows, got none")
+	}
+	if forecast.Hours[0].SpotId != spot.Id {
+		t.Errorf("Expected spot id %d, got %d", spot.Id, forecast.Hours[0].SpotId)
+	}
+	if forecast.Hours[0].Time.Before(fileDataStart) {
+		t.Errorf("Expected rows after %v, got %v", fileDataStart, forecast.Hours[0].Time)
+	}
+}
@@ -0,0 +1,162 @@
+package provider
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/ensemble"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/stormglass"
+)
+
+// stormglassProvider calls the Stormglass weather point and tide extremes APIs
+type stormglassProvider struct{}
+
+// fileProvider reads the Stormglass responses embedded in assets/data
+type fileProvider struct{}
+
+// the embedded data files cover October 2024 only, so the file provider
+// ignores the requested start and always serves this window
+var fileDataStart = time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+func init() {
+	Register(stormglassProvider{})
+	Register(fileProvider{})
+	RegisterTideProvider(stormglassProvider{})
+}
+
+func (stormglassProvider) Name() string {
+	return "stormglass"
+}
+
+func (p stormglassProvider) GetWeatherData(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*Forecast, error) {
+	weatherData, err := stormglass.DefaultClient().GetWeatherPoint(ctx, spot, start, duration)
+	if errors.Is(err, stormglass.ErrQuotaExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Forecast{Provider: p.Name(), IssuedAt: time.Now().UTC(), Hours: stormglassHoursToWeather(spot, weatherData.Hours)}, nil
+}
+
+func (p stormglassProvider) GetTideExtremes(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) ([]models.Tide, error) {
+	tideData, err := stormglass.DefaultClient().GetTideExtremes(ctx, spot, start, duration)
+	if errors.Is(err, stormglass.ErrQuotaExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return stormglassExtremesToTides(spot, p.Name(), tideData.Data), nil
+}
+
+func (fileProvider) Name() string {
+	return "file"
+}
+
+func (p fileProvider) GetWeatherData(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*Forecast, error) {
+	weatherData, err := stormglass.GetStormglassWeatherDataFromFile(spot, fileDataStart, duration)
+	if err != nil {
+		return nil, err
+	}
+	return &Forecast{Provider: p.Name(), IssuedAt: time.Now().UTC(), Hours: stormglassHoursToWeather(spot, weatherData.Hours)}, nil
+}
+
+func stormglassExtremesToTides(spot config.SpotConfig, source string, extremes []stormglass.TideExtreme) []models.Tide {
+	tides := make([]models.Tide, 0, len(extremes))
+	for _, extreme := range extremes {
+		tides = append(tides, models.Tide{
+			SpotId: spot.Id,
+			Time:   extreme.Time,
+			Height: extreme.Height,
+			Type:   extreme.Type,
+			Source: source,
+		})
+	}
+	return tides
+}
+
+// stormglass parameters holding directions, blended on the circle
+var directionParams = map[string]bool{
+	"swellDirection":          true,
+	"waveDirection":           true,
+	"windDirection":           true,
+	"secondarySwellDirection": true,
+	"windWaveDirection":       true,
+}
+
+// source configured for a parameter of a spot, sg when nothing is configured
+func sourceFor(spot config.SpotConfig, param string) string {
+	if source, ok := spot.Sources[param]; ok {
+		return source
+	}
+	if source, ok := spot.Sources["default"]; ok {
+		return source
+	}
+	return "sg"
+}
+
+// map stormglass hours to weather rows, scoring values are taken from the source configured
+// for each parameter of the spot (falling back to sg, missing without it), the spreads between sources of the main
+// scoring parameters are computed and every source value is kept
+func stormglassHoursToWeather(spot config.SpotConfig, hours []stormglass.Hour) []models.Weather {
+	weights := config.GetConfig().Stormglass.Weights
+	weatherRows := make([]models.Weather, 0, len(hours))
+	for _, hour := range hours {
+		parameters := hour.Parameters()
+		values := make(map[string]sql.NullFloat64, len(parameters))
+		var sources []models.SourceValue
+
+		for _, param := range stormglass.Params {
+			source := parameters[param]
+			value, ok := ensemble.Select(source.Values, sourceFor(spot, param), weights, directionParams[param])
+			if !ok {
+				value, ok = source.Values["sg"]
+			}
+			// a parameter no source gives stays missing
+			values[param] = sql.NullFloat64{Float64: value, Valid: ok}
+
+			for name, sourceValue := range source.Values {
+				sources = append(sources, models.SourceValue{Parameter: param, Source: name, Value: sourceValue})
+			}
+		}
+
+		weatherRows = append(weatherRows, models.Weather{
+			SpotId:                  spot.Id,
+			Time:                    hour.Time,
+			AirTemperature:          values["airTemperature"],
+			CurrentSpeed:            values["currentSpeed"],
+			SeaLevel:                values["seaLevel"],
+			SwellDirection:          values["swellDirection"],
+			SwellHeight:             values["swellHeight"],
+			SwellPeriod:             values["swellPeriod"],
+			WaterTemperature:        values["waterTemperature"],
+			WaveDirection:           values["waveDirection"],
+			WaveHeight:              values["waveHeight"],
+			WavePeriod:              values["wavePeriod"],
+			WindDirection:           values["windDirection"],
+			WindSpeed:               values["windSpeed"],
+			SecondarySwellDirection: values["secondarySwellDirection"],
+			SecondarySwellHeight:    values["secondarySwellHeight"],
+			SecondarySwellPeriod:    values["secondarySwellPeriod"],
+			WindWaveDirection:       values["windWaveDirection"],
+			WindWaveHeight:          values["windWaveHeight"],
+			WindWavePeriod:          values["windWavePeriod"],
+			Gust:                    values["gust"],
+			Precipitation:           values["precipitation"],
+			CloudCover:              values["cloudCover"],
+			Visibility:              values["visibility"],
+			Pressure:                values["pressure"],
+			WaveHeightSpread:        ensemble.Spread(parameters["waveHeight"].Values, weights, false),
+			SwellPeriodSpread:       ensemble.Spread(parameters["swellPeriod"].Values, weights, false),
+			WindSpeedSpread:         ensemble.Spread(parameters["windSpeed"].Values, weights, false),
+			Sources:                 sources,
+		})
+	}
+	return weatherRows
+}
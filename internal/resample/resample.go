@@ -0,0 +1,108 @@
+package resample
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+// DefaultMaxGap is the longest span between two forecast hours filled when none is configured,
+// it covers providers delivering 3 or 6-hourly data
+const DefaultMaxGap = 6 * time.Hour
+
+// Fill returns the hourly rows, sorted by time, with the hours missing between two rows
+// at most maxGap apart interpolated: linearly, and along the shortest arc for directions.
+// A parameter missing from one of the surrounding rows stays missing. Longer gaps are kept
+func Fill(rows []models.Weather, maxGap time.Duration) []models.Weather {
+	if len(rows) < 2 {
+		return rows
+	}
+
+	filled := make([]models.Weather, 0, len(rows))
+	for i, row := range rows {
+		if i > 0 {
+			previous := rows[i-1]
+			gap := row.Time.Sub(previous.Time)
+			if gap > time.Hour && gap <= maxGap {
+				for t := previous.Time.Add(time.Hour); t.Before(row.Time); t = t.Add(time.Hour) {
+					filled = append(filled, interpolate(previous, row, t))
+				}
+			}
+		}
+		filled = append(filled, row)
+	}
+	return filled
+}
+
+// row at t between the rows before and after it
+func interpolate(before, after models.Weather, t time.Time) models.Weather {
+	f := float64(t.Sub(before.Time)) / float64(after.Time.Sub(before.Time))
+	return models.Weather{
+		SpotId:                  before.SpotId,
+		RunId:                   before.RunId,
+		Time:                    t,
+		AirTemperature:          linear(before.AirTemperature, after.AirTemperature, f),
+		CurrentSpeed:            linear(before.CurrentSpeed, after.CurrentSpeed, f),
+		SeaLevel:                linear(before.SeaLevel, after.SeaLevel, f),
+		SwellDirection:          circular(before.SwellDirection, after.SwellDirection, f),
+		SwellHeight:             linear(before.SwellHeight, after.SwellHeight, f),
+		SwellPeriod:             linear(before.SwellPeriod, after.SwellPeriod, f),
+		WaterTemperature:        linear(before.WaterTemperature, after.WaterTemperature, f),
+		WaveDirection:           circular(before.WaveDirection, after.WaveDirection, f),
+		WaveHeight:              linear(before.WaveHeight, after.WaveHeight, f),
+		WavePeriod:              linear(before.WavePeriod, after.WavePeriod, f),
+		WindDirection:           circular(before.WindDirection, after.WindDirection, f),
+		WindSpeed:               linear(before.WindSpeed, after.WindSpeed, f),
+		SecondarySwellDirection: circular(before.SecondarySwellDirection, after.SecondarySwellDirection, f),
+		SecondarySwellHeight:    linear(before.SecondarySwellHeight, after.SecondarySwellHeight, f),
+		SecondarySwellPeriod:    linear(before.SecondarySwellPeriod, after.SecondarySwellPeriod, f),
+		WindWaveDirection:       circular(before.WindWaveDirection, after.WindWaveDirection, f),
+		WindWaveHeight:          linear(before.WindWaveHeight, after.WindWaveHeight, f),
+		WindWavePeriod:          linear(before.WindWavePeriod, after.WindWavePeriod, f),
+		Gust:                    linear(before.Gust, after.Gust, f),
+		Precipitation:           linear(before.Precipitation, after.Precipitation, f),
+		CloudCover:              linear(before.CloudCover, after.CloudCover, f),
+		Visibility:              linear(before.Visibility, after.Visibility, f),
+		Pressure:                linear(before.Pressure, after.Pressure, f),
+		WaveHeightSpread:        before.WaveHeightSpread + (after.WaveHeightSpread-before.WaveHeightSpread)*f,
+		SwellPeriodSpread:       before.SwellPeriodSpread + (after.SwellPeriodSpread-before.SwellPeriodSpread)*f,
+		WindSpeedSpread:         before.WindSpeedSpread + (after.WindSpeedSpread-before.WindSpeedSpread)*f,
+		IssuedAt:                before.IssuedAt,
+		Interpolated:            true,
+	}
+}
+
+func linear(a, b sql.NullFloat64, f float64) sql.NullFloat64 {
+	if !a.Valid || !b.Valid {
+		return sql.NullFloat64{}
+	}
+	return models.Float(a.Float64 + (b.Float64-a.Float64)*f)
+}
+
+// interpolation of directions in degrees along the shortest arc, 350° to 10° goes through 0°
+func circular(a, b sql.NullFloat64, f float64) sql.NullFloat64 {
+	if !a.Valid || !b.Valid {
+		return sql.NullFloat64{}
+	}
+	diff := math.Mod(b.Float64-a.Float64+540, 360) - 180
+	return models.Float(math.Mod(a.Float64+diff*f+360, 360))
+}
+
+// Every keeps one row every step, at the hours of the time zone multiple of step from midnight
+func Every(rows []models.Weather, step time.Duration, location *time.Location) []models.Weather {
+	if step <= time.Hour {
+		return rows
+	}
+
+	var sampled []models.Weather
+	for _, row := range rows {
+		local := row.Time.In(location)
+		sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+		if sinceMidnight%step == 0 {
+			sampled = append(sampled, row)
+		}
+	}
+	return sampled
+}
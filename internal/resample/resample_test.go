@@ -0,0 +1,114 @@
+package resample
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+var start = time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+func hour(h int, waveHeight, windDirection float64) models.Weather {
+	return models.Weather{
+		SpotId:        1,
+		Time:          start.Add(time.Duration(h) * time.Hour),
+		WaveHeight:    models.Float(waveHeight),
+		WindDirection: models.Float(windDirection),
+	}
+}
+
+func TestFill(t *testing.T) {
+	rows := []models.Weather{hour(0, 1.0, 350), hour(3, 2.5, 20), hour(4, 2.0, 30), hour(12, 1.0, 90)}
+	filled := Fill(rows, 6*time.Hour)
+
+	// the 8 hours gap is longer than the max gap and is kept
+	if len(filled) != 6 {
+		t.Fatalf("Expected 6 rows, got %d", len(filled))
+	}
+
+	testCases := []struct {
+		index         int
+		hour          int
+		waveHeight    float64
+		windDirection float64
+		interpolated  bool
+	}{
+		{0, 0, 1.0, 350, false},
+		{1, 1, 1.5, 0, true},
+		{2, 2, 2.0, 10, true},
+		{3, 3, 2.5, 20, false},
+		{4, 4, 2.0, 30, false},
+		{5, 12, 1.0, 90, false},
+	}
+
+	for _, tc := range testCases {
+		row := filled[tc.index]
+		if !row.Time.Equal(start.Add(time.Duration(tc.hour) * time.Hour)) {
+			t.Errorf("Expected row %d at hour %d, got %v", tc.index, tc.hour, row.Time)
+		}
+		if math.Abs(row.WaveHeight.Float64-tc.waveHeight) > 1e-9 {
+			t.Errorf("Expected wave height %f at hour %d, got %f", tc.waveHeight, tc.hour, row.WaveHeight.Float64)
+		}
+		if math.Abs(row.WindDirection.Float64-tc.windDirection) > 1e-9 {
+			t.Errorf("Expected wind direction %f at hour %d, got %f", tc.windDirection, tc.hour, row.WindDirection.Float64)
+		}
+		if row.Interpolated != tc.interpolated {
+			t.Errorf("Expected interpolated %t at hour %d, got %t", tc.interpolated, tc.hour, row.Interpolated)
+		}
+	}
+}
+
+func TestFillMissingParameter(t *testing.T) {
+	before := hour(0, 1.0, 270)
+	after := hour(2, 2.0, 270)
+	after.WindDirection = sql.NullFloat64{}
+
+	filled := Fill([]models.Weather{before, after}, 6*time.Hour)
+	if len(filled) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(filled))
+	}
+	if !filled[1].WaveHeight.Valid || filled[1].WindDirection.Valid {
+		t.Errorf("Expected a wave height and no wind direction, got %+v", filled[1])
+	}
+}
+
+func TestEvery(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []models.Weather
+	for h := 0; h < 24; h++ {
+		rows = append(rows, hour(h, 1.0, 270))
+	}
+
+	testCases := []struct {
+		label    string
+		step     time.Duration
+		location *time.Location
+		first    int
+		count    int
+	}{
+		{"hourly", time.Hour, time.UTC, 0, 24},
+		{"3 hours", 3 * time.Hour, time.UTC, 0, 8},
+		{"6 hours", 6 * time.Hour, time.UTC, 0, 4},
+		// 00:00 UTC is 02:00 CEST, the first 6 hours step of the day in Paris is 04:00 UTC
+		{"6 hours in Paris", 6 * time.Hour, paris, 4, 4},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			sampled := Every(rows, tc.step, tc.location)
+			if len(sampled) != tc.count {
+				t.Fatalf("Expected %d rows, got %d", tc.count, len(sampled))
+			}
+			if !sampled[0].Time.Equal(rows[tc.first].Time) {
+				t.Errorf("Expected first row at %v, got %v", rows[tc.first].Time, sampled[0].Time)
+			}
+		})
+	}
+}
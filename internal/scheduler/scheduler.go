@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/ingest"
+	"go-surf-forecast/internal/models"
+	"go-surf-forecast/internal/provider"
+)
+
+const (
+	defaultInterval   = 12 * time.Hour
+	defaultRetryDelay = 5 * time.Minute
+	forecastDays      = 7
+	// how often the scheduler looks for spots due for a refresh
+	checkInterval = time.Minute
+)
+
+// Scheduler periodically refreshes the forecast of every spot
+type Scheduler struct {
+	Ingester   ingest.Ingester
+	Refresh    models.RefreshModel
+	Spots      []config.SpotConfig
+	Interval   time.Duration
+	RetryDelay time.Duration
+}
+
+func New(ingester ingest.Ingester, spots []config.SpotConfig, cfg config.SchedulerConfig) *Scheduler {
+	s := &Scheduler{
+		Ingester:   ingester,
+		Refresh:    ingester.Refresh,
+		Spots:      spots,
+		Interval:   cfg.Interval,
+		RetryDelay: cfg.RetryDelay,
+	}
+	if s.Interval <= 0 {
+		s.Interval = defaultInterval
+	}
+	if s.RetryDelay <= 0 {
+		s.RetryDelay = defaultRetryDelay
+	}
+	return s
+}
+
+// Run refreshes spots as they become due until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Starting forecast scheduler, refresh interval = %s", s.Interval)
+
+	refreshes := make(map[int]models.SpotRefresh, len(s.Spots))
+	for _, spot := range s.Spots {
+		refreshes[spot.Id] = s.loadRefresh(spot.Id)
+	}
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	// set when the provider quota is used, nothing is fetched before it resets
+	var quotaResetAt time.Time
+
+	for {
+		if time.Now().After(quotaResetAt) {
+			for _, spot := range s.dueSpots(time.Now(), refreshes) {
+				err := s.refreshSpot(ctx, spot)
+				if errors.Is(err, provider.ErrQuotaExceeded) {
+					quotaResetAt = time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+					log.Printf("Provider quota exceeded, refreshes deferred until %s: %v", quotaResetAt.Format(time.RFC3339), err)
+					break
+				}
+				if err != nil {
+					log.Printf("Forecast refresh failed for spot %d: %v", spot.Id, err)
+				} else {
+					log.Printf("Forecast refreshed for spot %d", spot.Id)
+				}
+				refreshes[spot.Id] = s.loadRefresh(spot.Id)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping forecast scheduler")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh the forecast then the tides of a spot, a tide failure is logged only
+func (s *Scheduler) refreshSpot(ctx context.Context, spot config.SpotConfig) error {
+	if err := s.Ingester.IngestSpot(ctx, spot, time.Now(), forecastDays); err != nil {
+		return err
+	}
+	err := s.Ingester.IngestTides(ctx, spot, time.Now(), forecastDays)
+	if errors.Is(err, provider.ErrQuotaExceeded) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Tide refresh failed for spot %d: %v", spot.Id, err)
+	}
+	return nil
+}
+
+// read the refresh state of a spot, on error the spot is retried after RetryDelay
+func (s *Scheduler) loadRefresh(spotId int) models.SpotRefresh {
+	refresh, err := s.Refresh.GetSpotRefresh(spotId)
+	if err != nil {
+		log.Printf("Could not read refresh state for spot %d: %v", spotId, err)
+		return models.SpotRefresh{
+			SpotId:              spotId,
+			LastAttempt:         sql.NullTime{Time: time.Now(), Valid: true},
+			ConsecutiveFailures: 1,
+		}
+	}
+	return refresh
+}
+
+// spots due for a refresh, the stalest first so a limited quota goes to the oldest forecasts
+func (s *Scheduler) dueSpots(now time.Time, refreshes map[int]models.SpotRefresh) []config.SpotConfig {
+	var due []config.SpotConfig
+	for _, spot := range s.Spots {
+		if !now.Before(NextRun(refreshes[spot.Id], s.Interval, s.RetryDelay)) {
+			due = append(due, spot)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return lastSuccess(refreshes[due[i].Id]).Before(lastSuccess(refreshes[due[j].Id]))
+	})
+	return due
+}
+
+// last successful refresh, zero time for spots never refreshed
+func lastSuccess(refresh models.SpotRefresh) time.Time {
+	if refresh.LastSuccess.Valid {
+		return refresh.LastSuccess.Time
+	}
+	return time.Time{}
+}
+
+// NextRun returns when a spot should be refreshed again: one interval after
+// the last success, or after an exponential backoff when the last attempts failed
+func NextRun(refresh models.SpotRefresh, interval, retryDelay time.Duration) time.Time {
+	if refresh.ConsecutiveFailures > 0 && refresh.LastAttempt.Valid {
+		return refresh.LastAttempt.Time.Add(backoff(refresh.ConsecutiveFailures, retryDelay, interval))
+	}
+	if refresh.LastSuccess.Valid {
+		return refresh.LastSuccess.Time.Add(interval)
+	}
+	// never refreshed, due now
+	return time.Time{}
+}
+
+// retryDelay doubled for each consecutive failure, never more than maxDelay
+func backoff(failures int, retryDelay, maxDelay time.Duration) time.Duration {
+	delay := retryDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return min(delay, maxDelay)
+}
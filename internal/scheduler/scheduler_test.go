@@ -0,0 +1,74 @@
+package scheduler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+func TestBackoff(t *testing.T) {
+	testCases := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 5 * time.Minute},
+		{2, 10 * time.Minute},
+		{3, 20 * time.Minute},
+		{10, time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing backoff after %d failures", tc.failures)
+			result := backoff(tc.failures, 5*time.Minute, time.Hour)
+			if result != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	lastAttempt := time.Date(2024, time.October, 12, 10, 0, 0, 0, time.UTC)
+	lastSuccess := time.Date(2024, time.October, 12, 6, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		refresh  models.SpotRefresh
+		label    string
+		expected time.Time
+	}{
+		{
+			refresh:  models.SpotRefresh{},
+			label:    "never refreshed",
+			expected: time.Time{},
+		},
+		{
+			refresh: models.SpotRefresh{
+				LastAttempt: sql.NullTime{Time: lastSuccess, Valid: true},
+				LastSuccess: sql.NullTime{Time: lastSuccess, Valid: true},
+			},
+			label:    "last refresh succeeded",
+			expected: lastSuccess.Add(12 * time.Hour),
+		},
+		{
+			refresh: models.SpotRefresh{
+				LastAttempt:         sql.NullTime{Time: lastAttempt, Valid: true},
+				LastSuccess:         sql.NullTime{Time: lastSuccess, Valid: true},
+				ConsecutiveFailures: 2,
+			},
+			label:    "last refresh failed twice",
+			expected: lastAttempt.Add(10 * time.Minute),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			result := NextRun(tc.refresh, 12*time.Hour, 5*time.Minute)
+			if !result.Equal(tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
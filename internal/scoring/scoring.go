@@ -1,15 +1,43 @@
 package scoring
 
 import (
+	"database/sql"
+	"fmt"
 	"go-surf-forecast/config"
 	"go-surf-forecast/internal/models"
 	"math"
+	"strings"
 )
 
+// parameters of the score of a spot, its scoring merged into the defaults
+type parameters struct {
+	waveWeight, swellWeight, windWeight, comfortWeight float64
+	idealWaveHeightMin, idealWaveHeightMax             float64
+	idealSwellPeriod                                   float64
+	idealTemperature                                   float64
+	lightWind                                          float64
+}
+
+// parameters of a spot, LoadConfig has already merged the global scoring section into its scoring
+func parametersOf(spot config.SpotConfig) parameters {
+	scoring := config.DefaultScoring.Merge(spot.Scoring)
+	return parameters{
+		waveWeight:         *scoring.Weights.Wave,
+		swellWeight:        *scoring.Weights.Swell,
+		windWeight:         *scoring.Weights.Wind,
+		comfortWeight:      *scoring.Weights.Comfort,
+		idealWaveHeightMin: *scoring.WaveHeight.Min,
+		idealWaveHeightMax: *scoring.WaveHeight.Max,
+		idealSwellPeriod:   *scoring.SwellPeriod,
+		idealTemperature:   *scoring.Temperature,
+		lightWind:          *scoring.LightWind,
+	}
+}
+
 // scale wage height to a value between 0 and 5
-func scaleWaveHeight(waveHeight float64) float64 {
-	idealWaveHeightMin := 0.8
-	idealWaveHeightMax := 2.0
+func scaleWaveHeight(waveHeight float64, p parameters) float64 {
+	idealWaveHeightMin := p.idealWaveHeightMin
+	idealWaveHeightMax := p.idealWaveHeightMax
 
 	if waveHeight < idealWaveHeightMin {
 		return (waveHeight / idealWaveHeightMin) * 5
@@ -23,78 +51,435 @@ func scaleWaveHeight(waveHeight float64) float64 {
 	return 5
 }
 
-// scale swell direction to a value between 0 and 5
-func scaleSwellDirection(swellDirection float64, spotDirection int) float64 {
-	directionDiff := math.Abs(swellDirection - float64(spotDirection))
-	// Calculate direction difference (mod 360 to handle wrap-around cases)
-	// 350° and 10° are only 20° apart for example
-	if directionDiff > 180 {
-		directionDiff = 360 - directionDiff
+// soft edge of the swell window arcs configured without one
+const defaultSwellEdge = 20.0
+
+// swell window of a spot: its configured arcs, or its direction alone fading out over 90°
+func swellWindow(spot config.SpotConfig) []config.SwellArcConfig {
+	if len(spot.SwellWindow) > 0 {
+		return spot.SwellWindow
 	}
+	direction := float64(spot.Direction)
+	return []config.SwellArcConfig{{From: direction, To: direction, Edge: 90}}
+}
 
-	// 0° = 5, 90° = 0, 180° = -5
-	directionScore := 5 - (directionDiff / 18)
-	return math.Max(0, directionScore)
+// scale swell direction to a value between 0 and 5: 5 inside an arc of the swell window of the spot,
+// decreasing to 0 across the soft edge of the closest arc. Sectors between arcs are shadowed
+func scaleSwellDirection(swellDirection float64, spot config.SpotConfig) float64 {
+	var best float64
+	for _, arc := range swellWindow(spot) {
+		edge := arc.Edge
+		if edge <= 0 {
+			edge = defaultSwellEdge
+		}
+		// angles measured clockwise from the start of the arc, 350° is 20° from 10° for example
+		width := math.Mod(arc.To-arc.From+360, 360)
+		offset := math.Mod(swellDirection-arc.From+360, 360)
+		var distance float64
+		if offset > width {
+			distance = math.Min(offset-width, 360-offset)
+		}
+		best = math.Max(best, 5-distance*5/edge)
+	}
+	return best
 }
 
 // scale swell period to a value between 0 and 5
-func scaleSwellPeriod(swellPeriod float64) float64 {
-	// Swell period scaling: Long periods (10s+) are usually better
+func scaleSwellPeriod(swellPeriod float64, p parameters) float64 {
+	// Swell period scaling: Long periods (10s+ by default) are usually better
 	var periodScore float64
-	if swellPeriod >= 10 {
+	if swellPeriod >= p.idealSwellPeriod {
 		periodScore = 5
 	} else {
-		periodScore = swellPeriod / 2 // Scale period to 0-5 for periods less than 10s
+		periodScore = swellPeriod * 5 / p.idealSwellPeriod // Scale period to 0-5 for shorter periods
 	}
 	return periodScore
 }
 
-func calculateSwellScore(swellHeight, swellPeriod, swellDirection float64, spot config.SpotConfig) float64 {
-	directionScore := scaleSwellDirection(swellDirection, spot.Direction)
-	periodScore := scaleSwellPeriod(swellPeriod)
-	heightScore := scaleWaveHeight(swellHeight)
+// weighted score of a component, ignored when its inputs are missing
+type weightedScore struct {
+	weight float64
+	score  float64
+	ok     bool
+}
+
+// weighted mean of the available scores, the missing ones giving their weight to the others.
+// It returns false when no score is available
+func weightedMean(scores ...weightedScore) (float64, bool) {
+	var sum, totalWeight float64
+	for _, s := range scores {
+		if s.ok {
+			sum += s.weight * s.score
+			totalWeight += s.weight
+		}
+	}
+	if totalWeight == 0 {
+		return 0, false
+	}
+	return sum / totalWeight, true
+}
+
+// score of one swell train from its height, period and direction
+func calculateSwellTrainScore(swellHeight, swellPeriod, swellDirection sql.NullFloat64, spot config.SpotConfig) (float64, bool) {
+	p := parametersOf(spot)
+	return weightedMean(
+		weightedScore{0.4, scaleWaveHeight(swellHeight.Float64, p), swellHeight.Valid},
+		weightedScore{0.4, scaleSwellPeriod(swellPeriod.Float64, p), swellPeriod.Valid},
+		weightedScore{0.2, scaleSwellDirection(swellDirection.Float64, spot), swellDirection.Valid},
+	)
+}
+
+// swellTrain is one swell partition of an hour
+type swellTrain struct {
+	name                      string
+	height, period, direction sql.NullFloat64
+}
+
+// best swell train of an hour, the dominant or the secondary one, with its score: a clean groundswell
+// under a short period swell makes the surf even when it is not the dominant train
+func bestSwellTrain(weatherModel models.Weather, spot config.SpotConfig) (swellTrain, float64, bool) {
+	train := swellTrain{"swell", weatherModel.SwellHeight, weatherModel.SwellPeriod, weatherModel.SwellDirection}
+	score, ok := calculateSwellTrainScore(train.height, train.period, train.direction, spot)
+	// a secondary train is only known with its height
+	if !weatherModel.SecondarySwellHeight.Valid {
+		return train, score, ok
+	}
+	// a missing secondary period or direction is taken from the dominant train, scoring the
+	// secondary on its height alone would let missing data beat a complete dominant train
+	secondary := swellTrain{
+		"secondary swell",
+		weatherModel.SecondarySwellHeight,
+		orElse(weatherModel.SecondarySwellPeriod, weatherModel.SwellPeriod),
+		orElse(weatherModel.SecondarySwellDirection, weatherModel.SwellDirection),
+	}
+	secondaryScore, _ := calculateSwellTrainScore(secondary.height, secondary.period, secondary.direction, spot)
+	if !ok || secondaryScore > score {
+		return secondary, secondaryScore, true
+	}
+	return train, score, true
+}
+
+// value, or fallback when value is missing
+func orElse(value, fallback sql.NullFloat64) sql.NullFloat64 {
+	if value.Valid {
+		return value
+	}
+	return fallback
+}
+
+// score of the best swell train, the dominant or the secondary one
+func calculateSwellScore(weatherModel models.Weather, spot config.SpotConfig) (float64, bool) {
+	_, score, ok := bestSwellTrain(weatherModel, spot)
+	return score, ok
+}
+
+// windClass is a sector of wind directions relative to the beach, with its score by speed band
+type windClass struct {
+	name string
+	// largest angle between the wind and the onshore direction in the class
+	maxAngle float64
+	// scores of the light, moderate, strong and very strong speed bands
+	scores [4]float64
+}
+
+// wind classes by angle from dead onshore, the wind blowing from the sea the beach faces
+var windClasses = []windClass{
+	{"onshore", 30, [4]float64{2.5, 1, 0, 0}},
+	{"cross-onshore", 70, [4]float64{3, 2, 0.5, 0}},
+	{"cross", 110, [4]float64{4, 3, 1.5, 0}},
+	{"cross-offshore", 150, [4]float64{5, 4, 2.5, 0.5}},
+	// a strong offshore wind holds the waves up and keeps the surfers from catching them
+	{"offshore", 180, [4]float64{5, 4.5, 3, 1}},
+}
+
+// upper bounds in m/s of the light, moderate and strong wind speed bands, very strong beyond.
+// They scale with the light wind of the scoring parameters
+var windSpeedBands = [3]float64{5, 8, 12}
+
+// wind speed under which the sea stays glassy whatever the direction, m/s
+const calmWindSpeed = 2.0
+
+// direction the beach of a spot faces, towards the sea: its configured facing, or its direction
+func beachFacing(spot config.SpotConfig) float64 {
+	if spot.Facing != nil {
+		return *spot.Facing
+	}
+	return float64(spot.Direction)
+}
+
+// class of a wind direction (where the wind comes from) at a spot
+func classifyWind(windDirection float64, spot config.SpotConfig) windClass {
+	// 0° when the wind blows from the sea, 180° when it blows from the land
+	angle := math.Abs(math.Mod(windDirection-beachFacing(spot)+540, 360) - 180)
+	for _, class := range windClasses {
+		if angle <= class.maxAngle {
+			return class
+		}
+	}
+	return windClasses[len(windClasses)-1]
+}
 
-	swellScore := (0.4 * heightScore) + (0.4 * periodScore) + (0.2 * directionScore)
-	return swellScore
+// WindClass returns how the wind of an hour blows at a spot: offshore, cross-offshore, cross,
+// cross-onshore or onshore, empty when the wind is unknown
+func WindClass(spot config.SpotConfig, weatherModel models.Weather) string {
+	if !weatherModel.WindSpeed.Valid || !weatherModel.WindDirection.Valid {
+		return ""
+	}
+	return classifyWind(weatherModel.WindDirection.Float64, spot).name
 }
 
 // Function to calculate wind score based on speed and direction
 func calculateWindScore(windSpeed, windDirection float64, spot config.SpotConfig) float64 {
-	// Offshore wind is best, which occurs when wind blows from land to sea
-	// Calculate angle difference between wind and coastline direction
-	windDiff := math.Abs(windDirection - float64(spot.Direction))
-	if windDiff > 180 {
-		windDiff = 360 - windDiff
+	if windSpeed < calmWindSpeed {
+		return 5
+	}
+	class := classifyWind(windDirection, spot)
+	lightWind := parametersOf(spot).lightWind
+	for band, maxSpeed := range windSpeedBands {
+		if windSpeed <= maxSpeed*lightWind/windSpeedBands[0] {
+			return class.scores[band]
+		}
 	}
+	return class.scores[len(windSpeedBands)]
+}
 
-	// 180° is perfect offshore
-	windDirectionScore := 5 - (windDiff / 18)
+// penalty of the wind score for gusts, irregular wind blowing more than 4 m/s above its mean
+// speed makes the waves choppy, each 2 m/s beyond costs a point
+func gustPenalty(windSpeed, gust sql.NullFloat64) float64 {
+	if !windSpeed.Valid || !gust.Valid {
+		return 0
+	}
+	return math.Max(0, gust.Float64-windSpeed.Float64-4) / 2
+}
 
-	// Scale wind speed: Light winds (under 5 m/s) are ideal
-	if windSpeed <= 5 {
-		return windDirectionScore
+// penalty of the comfort score for rain, from 0 under moderate rain (2.5 mm/h) to 5 for heavy rain (7.6 mm/h)
+func rainPenalty(precipitation sql.NullFloat64) float64 {
+	if !precipitation.Valid {
+		return 0
 	}
-	return windDirectionScore - ((windSpeed - 5) / 2) // Penalty for high wind
+	return math.Min(5, math.Max(0, (precipitation.Float64-2.5)/(7.6-2.5)*5))
 }
 
-// calculate comfort score based on water temperature and air temperature
-func calculateComfort(waterTemperature, airTemperature float64) float64 {
-	// we use 22 as the ideal temperature
-	waterScore := 5 - math.Abs(22-waterTemperature)
-	airScore := 5 - math.Abs(22-airTemperature)
-	comfortScore := (0.5 * waterScore) + (0.5 * airScore)
-	return comfortScore
+// calculate comfort score between 0 and 5 based on water temperature, air temperature and rain
+func calculateComfort(waterTemperature, airTemperature, precipitation sql.NullFloat64, p parameters) (float64, bool) {
+	// 22 °C is the ideal temperature by default
+	waterScore := 5 - math.Abs(p.idealTemperature-waterTemperature.Float64)
+	airScore := 5 - math.Abs(p.idealTemperature-airTemperature.Float64)
+	score, ok := weightedMean(
+		weightedScore{0.5, waterScore, waterTemperature.Valid},
+		weightedScore{0.5, airScore, airTemperature.Valid},
+	)
+	return clampScore(score - rainPenalty(precipitation)), ok
 }
 
-func CalculateScoreSpotByHour(spot config.SpotConfig, weatherModel models.Weather) float64 {
-	if weatherModel.WaveHeight == 0.0 {
-		return 0.0
+// keep a component score between 0 and 5 once its penalties are applied
+func clampScore(score float64) float64 {
+	return math.Max(0, math.Min(5, score))
+}
+
+// bounds of the tide levels of a stage, false for an unknown stage
+func tideStageBounds(stage string) (float64, float64, bool) {
+	switch stage {
+	case "low":
+		return 0, 1.0 / 3, true
+	case "mid":
+		return 1.0 / 3, 2.0 / 3, true
+	case "high":
+		return 2.0 / 3, 1, true
+	}
+	return 0, 0, false
+}
+
+// scale the tide to a value between 0 and 5 against the tide preference of a spot
+func scaleTide(tide models.TideState, preference config.TidePreferenceConfig) float64 {
+	if (preference.Movement == "rising" && !tide.Rising) || (preference.Movement == "falling" && tide.Rising) {
+		return 0
+	}
+
+	score := 5.0
+	if len(preference.Stages) > 0 {
+		// a full stage away from the closest preferred stage scores 0
+		distance := 1.0
+		for _, stage := range preference.Stages {
+			low, high, ok := tideStageBounds(stage)
+			if !ok {
+				continue
+			}
+			distance = math.Min(distance, math.Max(0, math.Max(low-tide.Level, tide.Level-high)))
+		}
+		score = math.Min(score, 5-distance*15)
+	}
+	// 1 m out of the sea level range scores 0
+	if preference.MinHeight != nil && tide.Height < *preference.MinHeight {
+		score = math.Min(score, 5-(*preference.MinHeight-tide.Height)*5)
+	}
+	if preference.MaxHeight != nil && tide.Height > *preference.MaxHeight {
+		score = math.Min(score, 5-(tide.Height-*preference.MaxHeight)*5)
 	}
-	waveScore := scaleWaveHeight(weatherModel.WaveHeight)
-	swellScore := calculateSwellScore(weatherModel.SwellHeight, weatherModel.SwellPeriod, weatherModel.SwellDirection, spot)
-	windScore := calculateWindScore(weatherModel.WindSpeed, weatherModel.WindDirection, spot)
-	comfortScore := calculateComfort(weatherModel.WaterTemperature, weatherModel.AirTemperature)
+	return math.Max(0, score)
+}
+
+// MissingInputs returns the scoring parameters the provider did not give for an hour
+func MissingInputs(weatherModel models.Weather) []string {
+	inputs := []struct {
+		name  string
+		value sql.NullFloat64
+	}{
+		{"wave_height", weatherModel.WaveHeight},
+		{"swell_height", weatherModel.SwellHeight},
+		{"swell_period", weatherModel.SwellPeriod},
+		{"swell_direction", weatherModel.SwellDirection},
+		{"wind_speed", weatherModel.WindSpeed},
+		{"wind_direction", weatherModel.WindDirection},
+		{"water_temperature", weatherModel.WaterTemperature},
+		{"air_temperature", weatherModel.AirTemperature},
+	}
+	var missing []string
+	for _, input := range inputs {
+		if !input.value.Valid {
+			missing = append(missing, input.name)
+		}
+	}
+	return missing
+}
 
-	finalScore := (0.5 * waveScore) + (0.25 * swellScore) + (0.2 * windScore) + (0.05 * comfortScore)
-	return finalScore
+// Component is the part of one component in the rating of an hour
+type Component struct {
+	// wave, swell, wind, comfort or tide
+	Name string
+	// between 0 and 5
+	Score float64
+	// share of the component in the rating, 0 when its inputs are missing
+	Weight float64
+	// inputs of the component given by the provider, by parameter name
+	Inputs map[string]float64
+	// the inputs and the points the component costs the rating, e.g. "wind 8.0 m/s onshore: -0.80"
+	Reason string
+}
+
+// input of a component and how it reads in a reason
+type input struct {
+	name   string
+	value  sql.NullFloat64
+	format string
+}
+
+// component with the given inputs, described by the valid ones after its label
+func newComponent(name, label string, inputs ...input) Component {
+	component := Component{Name: name, Inputs: map[string]float64{}}
+	var parts []string
+	for _, in := range inputs {
+		if in.value.Valid {
+			component.Inputs[in.name] = in.value.Float64
+			parts = append(parts, fmt.Sprintf(in.format, in.value.Float64))
+		}
+	}
+	component.Reason = strings.TrimSpace(label + " " + strings.Join(parts, ", "))
+	return component
+}
+
+// ExplainScoreSpotByHour rates an hour like CalculateScoreSpotByHour and returns the components
+// of the rating. The points the components cost, their weight times their score minus 5, add up
+// to the rating minus 5
+func ExplainScoreSpotByHour(spot config.SpotConfig, weatherModel models.Weather) (float64, []Component) {
+	waveInput := input{"wave_height", weatherModel.WaveHeight, "%.1f m"}
+	// a known flat sea, unlike a missing wave height
+	if weatherModel.WaveHeight.Valid && weatherModel.WaveHeight.Float64 == 0.0 {
+		wave := newComponent("wave", "flat sea", waveInput)
+		wave.Weight = 1
+		return 0.0, []Component{wave}
+	}
+	train, swellScore, swellOk := bestSwellTrain(weatherModel, spot)
+	if !weatherModel.WaveHeight.Valid && !swellOk {
+		wave := newComponent("wave", "no wave or swell data")
+		wave.Weight = 1
+		return 0.0, []Component{wave}
+	}
+	p := parametersOf(spot)
+	waveScore := scaleWaveHeight(weatherModel.WaveHeight.Float64, p)
+	windOk := weatherModel.WindSpeed.Valid && weatherModel.WindDirection.Valid
+	windScore := clampScore(calculateWindScore(weatherModel.WindSpeed.Float64, weatherModel.WindDirection.Float64, spot) -
+		gustPenalty(weatherModel.WindSpeed, weatherModel.Gust))
+	comfortScore, comfortOk := calculateComfort(weatherModel.WaterTemperature, weatherModel.AirTemperature, weatherModel.Precipitation, p)
+
+	scores := []weightedScore{
+		{p.waveWeight, waveScore, weatherModel.WaveHeight.Valid},
+		{p.swellWeight, swellScore, swellOk},
+		{p.windWeight, windScore, windOk},
+		{p.comfortWeight, comfortScore, comfortOk},
+	}
+	finalScore, _ := weightedMean(scores...)
+
+	windSpeedFormat := "%.1f m/s " + WindClass(spot, weatherModel)
+	if windOk && weatherModel.WindSpeed.Float64 < calmWindSpeed {
+		windSpeedFormat += " (calm)"
+	}
+	// secondary_swell_height for the secondary train, like the stored parameters
+	swellPrefix := strings.ReplaceAll(train.name, " ", "_")
+	components := []Component{
+		newComponent("wave", "waves", waveInput),
+		newComponent("swell", train.name,
+			input{swellPrefix + "_height", train.height, "%.1f m"},
+			input{swellPrefix + "_period", train.period, "%.0f s"},
+			input{swellPrefix + "_direction", train.direction, "from %.0f°"},
+		),
+		newComponent("wind", "wind",
+			input{"wind_speed", weatherModel.WindSpeed, windSpeedFormat},
+			input{"wind_direction", weatherModel.WindDirection, "from %.0f°"},
+			input{"gust", weatherModel.Gust, "gusts %.1f m/s"},
+		),
+		newComponent("comfort", "",
+			input{"water_temperature", weatherModel.WaterTemperature, "water %.0f °C"},
+			input{"air_temperature", weatherModel.AirTemperature, "air %.0f °C"},
+			input{"precipitation", weatherModel.Precipitation, "rain %.1f mm/h"},
+		),
+	}
+	var totalWeight float64
+	for _, s := range scores {
+		if s.ok {
+			totalWeight += s.weight
+		}
+	}
+	for i, s := range scores {
+		if s.ok {
+			components[i].Score = s.score
+			components[i].Weight = s.weight / totalWeight
+		} else {
+			components[i].Reason = components[i].Name + " unknown"
+		}
+	}
+
+	// the tide takes its share of the score, hours without tide data keep the other components
+	tideWeight := math.Min(spot.TidePreference.Weight, 1)
+	if tideWeight > 0 && weatherModel.Tide != nil {
+		tideScore := scaleTide(*weatherModel.Tide, spot.TidePreference)
+		finalScore = (1-tideWeight)*finalScore + tideWeight*tideScore
+		for i := range components {
+			components[i].Weight *= 1 - tideWeight
+		}
+		movement := "falling"
+		if weatherModel.Tide.Rising {
+			movement = "rising"
+		}
+		tide := newComponent("tide", "tide "+weatherModel.Tide.Stage()+" "+movement,
+			input{"tide_height", models.Float(weatherModel.Tide.Height), "at %.1f m"},
+		)
+		tide.Score = tideScore
+		tide.Weight = tideWeight
+		components = append(components, tide)
+	}
+
+	for i, component := range components {
+		if component.Weight > 0 {
+			components[i].Reason += fmt.Sprintf(": %.2f", component.Weight*(component.Score-5))
+		}
+	}
+	return finalScore, components
+}
+
+// CalculateScoreSpotByHour rates an hour from 0 to 5. Components with missing inputs are left out
+// and their weight shared by the others, an hour without any wave or swell data rates 0
+func CalculateScoreSpotByHour(spot config.SpotConfig, weatherModel models.Weather) float64 {
+	score, _ := ExplainScoreSpotByHour(spot, weatherModel)
+	return score
 }
@@ -3,6 +3,7 @@ package scoring
 import (
 	"go-surf-forecast/config"
 	"go-surf-forecast/internal/models"
+	"math"
 	"testing"
 )
 
@@ -24,7 +25,7 @@ func TestScaleWaveHeight(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run("", func(t *testing.T) {
 			t.Logf("Testing wave height scale %f", tc.waveHeight)
-			result := scaleWaveHeight(tc.waveHeight)
+			result := scaleWaveHeight(tc.waveHeight, parametersOf(config.SpotConfig{}))
 			if result != tc.expected {
 				t.Errorf("Expected %f, got %f", tc.expected, result)
 			}
@@ -50,7 +51,176 @@ func TestScaleSwellDirection(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run("", func(t *testing.T) {
 			t.Logf("Testing swell direction scale %f against spot %d", tc.swellDirection, tc.spotDirection)
-			result := scaleSwellDirection(tc.swellDirection, tc.spotDirection)
+			result := scaleSwellDirection(tc.swellDirection, config.SpotConfig{Direction: tc.spotDirection})
+			if result != tc.expected {
+				t.Errorf("Expected %f, got %f", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestScaleSwellDirectionWindow(t *testing.T) {
+	// a northern and a western arc, the north-west sector between them is shadowed
+	spot := config.SpotConfig{
+		Direction: 0,
+		SwellWindow: []config.SwellArcConfig{
+			{From: 340, To: 20, Edge: 10},
+			{From: 250, To: 290},
+		},
+	}
+
+	testCases := []struct {
+		swellDirection float64
+		expected       float64
+	}{
+		{0.0, 5.0},
+		{340.0, 5.0},
+		{20.0, 5.0},
+		{25.0, 2.5},
+		{335.0, 2.5},
+		{30.0, 0.0},
+		{270.0, 5.0},
+		{295.0, 3.75},
+		{245.0, 3.75},
+		{315.0, 0.0},
+		{180.0, 0.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing swell direction scale %f against the swell window", tc.swellDirection)
+			result := scaleSwellDirection(tc.swellDirection, spot)
+			if result != tc.expected {
+				t.Errorf("Expected %f, got %f", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestCalculateSwellScore(t *testing.T) {
+	testCases := []struct {
+		weather  models.Weather
+		label    string
+		expected float64
+		ok       bool
+	}{
+		{
+			weather: models.Weather{
+				SwellHeight:    models.Float(1.0),
+				SwellPeriod:    models.Float(6.0),
+				SwellDirection: models.Float(90.0),
+			},
+			label:    "dominant swell only",
+			expected: 4.2,
+			ok:       true,
+		},
+		{
+			weather: models.Weather{
+				SwellHeight:             models.Float(1.0),
+				SwellPeriod:             models.Float(6.0),
+				SwellDirection:          models.Float(90.0),
+				SecondarySwellHeight:    models.Float(1.0),
+				SecondarySwellPeriod:    models.Float(14.0),
+				SecondarySwellDirection: models.Float(90.0),
+			},
+			label:    "groundswell under a short period swell",
+			expected: 5.0,
+			ok:       true,
+		},
+		{
+			weather: models.Weather{
+				SwellHeight:             models.Float(1.0),
+				SwellPeriod:             models.Float(12.0),
+				SwellDirection:          models.Float(90.0),
+				SecondarySwellHeight:    models.Float(0.4),
+				SecondarySwellPeriod:    models.Float(5.0),
+				SecondarySwellDirection: models.Float(0.0),
+			},
+			label:    "weaker secondary swell",
+			expected: 5.0,
+			ok:       true,
+		},
+		{
+			weather: models.Weather{
+				SwellHeight:          models.Float(1.0),
+				SwellPeriod:          models.Float(6.0),
+				SwellDirection:       models.Float(90.0),
+				SecondarySwellHeight: models.Float(1.0),
+			},
+			label:    "secondary swell without period takes the dominant one",
+			expected: 4.2,
+			ok:       true,
+		},
+		{
+			weather: models.Weather{
+				SwellHeight:          models.Float(1.0),
+				SwellPeriod:          models.Float(6.0),
+				SwellDirection:       models.Float(0.0),
+				SecondarySwellHeight: models.Float(1.0),
+				SecondarySwellPeriod: models.Float(14.0),
+			},
+			label:    "secondary swell without direction takes the dominant one",
+			expected: 4.0,
+			ok:       true,
+		},
+		{
+			weather: models.Weather{
+				SecondarySwellHeight:    models.Float(0.8),
+				SecondarySwellPeriod:    models.Float(10.0),
+				SecondarySwellDirection: models.Float(90.0),
+			},
+			label:    "secondary swell only",
+			expected: 5.0,
+			ok:       true,
+		},
+		{
+			weather:  models.Weather{},
+			label:    "no swell",
+			expected: 0.0,
+			ok:       false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			result, ok := calculateSwellScore(tc.weather, config.SpotConfig{Direction: 90})
+			if ok != tc.ok || math.Abs(result-tc.expected) > 1e-9 {
+				t.Errorf("Expected %f (%t), got %f (%t)", tc.expected, tc.ok, result, ok)
+			}
+		})
+	}
+}
+
+func TestCalculateWindScore(t *testing.T) {
+	// a beach facing west, the swell coming from 300°
+	facing := 270.0
+	spot := config.SpotConfig{Direction: 300, Facing: &facing}
+
+	testCases := []struct {
+		windSpeed     float64
+		windDirection float64
+		class         string
+		expected      float64
+	}{
+		{4.0, 90.0, "offshore", 5.0},
+		{10.0, 90.0, "offshore", 3.0},
+		{4.0, 140.0, "cross-offshore", 5.0},
+		{6.0, 0.0, "cross", 3.0},
+		{6.0, 200.0, "cross-onshore", 2.0},
+		{4.0, 270.0, "onshore", 2.5},
+		{10.0, 300.0, "onshore", 0.0},
+		{15.0, 90.0, "offshore", 1.0},
+		{1.0, 270.0, "onshore", 5.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing wind score of %f m/s from %f", tc.windSpeed, tc.windDirection)
+			weather := models.Weather{WindSpeed: models.Float(tc.windSpeed), WindDirection: models.Float(tc.windDirection)}
+			if class := WindClass(spot, weather); class != tc.class {
+				t.Errorf("Expected %s, got %s", tc.class, class)
+			}
+			result := calculateWindScore(tc.windSpeed, tc.windDirection, spot)
 			if result != tc.expected {
 				t.Errorf("Expected %f, got %f", tc.expected, result)
 			}
@@ -59,6 +229,7 @@ func TestScaleSwellDirection(t *testing.T) {
 }
 
 func TestCalculateScoreSpotByHour(t *testing.T) {
+	noWeight := 0.0
 	testCases := []struct {
 		spot     config.SpotConfig
 		weather  models.Weather
@@ -68,14 +239,14 @@ func TestCalculateScoreSpotByHour(t *testing.T) {
 		{
 			spot: config.SpotConfig{Direction: 90},
 			weather: models.Weather{
-				WaveHeight:       1.0,
-				SwellHeight:      1.0,
-				SwellPeriod:      10.0,
-				SwellDirection:   90.0,
-				WindSpeed:        4.0,
-				WindDirection:    90.0,
-				WaterTemperature: 22.0,
-				AirTemperature:   22.0,
+				WaveHeight:       models.Float(1.0),
+				SwellHeight:      models.Float(1.0),
+				SwellPeriod:      models.Float(10.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				WaterTemperature: models.Float(22.0),
+				AirTemperature:   models.Float(22.0),
 			},
 			label:    "perfect conditions",
 			expected: 5.0,
@@ -83,18 +254,107 @@ func TestCalculateScoreSpotByHour(t *testing.T) {
 		{
 			spot: config.SpotConfig{Direction: 0},
 			weather: models.Weather{
-				WaveHeight:       0.0,
-				SwellHeight:      2.0,
-				SwellPeriod:      8.0,
-				SwellDirection:   90.0,
-				WindSpeed:        15.0,
-				WindDirection:    270.0,
-				WaterTemperature: 20.0,
-				AirTemperature:   18.0,
+				WaveHeight:       models.Float(0.0),
+				SwellHeight:      models.Float(2.0),
+				SwellPeriod:      models.Float(8.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(15.0),
+				WindDirection:    models.Float(270.0),
+				WaterTemperature: models.Float(20.0),
+				AirTemperature:   models.Float(18.0),
 			},
 			label:    "no wave",
 			expected: 0.0,
 		},
+		{
+			spot: config.SpotConfig{Direction: 90, TidePreference: config.TidePreferenceConfig{Weight: 0.5, Stages: []string{"high"}}},
+			weather: models.Weather{
+				WaveHeight:       models.Float(1.0),
+				SwellHeight:      models.Float(1.0),
+				SwellPeriod:      models.Float(10.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				WaterTemperature: models.Float(22.0),
+				AirTemperature:   models.Float(22.0),
+				Tide:             &models.TideState{Level: 0},
+			},
+			label:    "perfect conditions at the wrong tide",
+			expected: 2.5,
+		},
+		{
+			spot: config.SpotConfig{Direction: 90},
+			weather: models.Weather{
+				SwellHeight:      models.Float(1.0),
+				SwellPeriod:      models.Float(10.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				WaterTemperature: models.Float(22.0),
+			},
+			label:    "missing wave height and air temperature",
+			expected: 5.0,
+		},
+		{
+			spot: config.SpotConfig{Direction: 90},
+			weather: models.Weather{
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				WaterTemperature: models.Float(22.0),
+				AirTemperature:   models.Float(22.0),
+			},
+			label:    "missing wave and swell",
+			expected: 0.0,
+		},
+		{
+			spot: config.SpotConfig{Direction: 90},
+			weather: models.Weather{
+				WaveHeight:       models.Float(1.0),
+				SwellHeight:      models.Float(1.0),
+				SwellPeriod:      models.Float(10.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				Gust:             models.Float(12.0),
+				WaterTemperature: models.Float(22.0),
+				AirTemperature:   models.Float(22.0),
+				Precipitation:    models.Float(10.0),
+			},
+			label:    "gusty wind and heavy rain",
+			expected: 4.35,
+		},
+		{
+			spot: config.SpotConfig{Direction: 90},
+			weather: models.Weather{
+				WaveHeight:       models.Float(1.0),
+				SwellHeight:      models.Float(1.0),
+				SwellPeriod:      models.Float(10.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				Gust:             models.Float(40.0),
+				WaterTemperature: models.Float(10.0),
+				AirTemperature:   models.Float(8.0),
+				Precipitation:    models.Float(50.0),
+			},
+			label:    "storm gusts and cloudburst cost no more than the wind and comfort weights",
+			expected: 3.75,
+		},
+		{
+			spot: config.SpotConfig{Direction: 90, Scoring: config.ScoringConfig{Weights: config.ScoringWeightsConfig{Comfort: &noWeight}}},
+			weather: models.Weather{
+				WaveHeight:       models.Float(1.0),
+				SwellHeight:      models.Float(1.0),
+				SwellPeriod:      models.Float(10.0),
+				SwellDirection:   models.Float(90.0),
+				WindSpeed:        models.Float(4.0),
+				WindDirection:    models.Float(270.0),
+				WaterTemperature: models.Float(10.0),
+				AirTemperature:   models.Float(8.0),
+			},
+			label:    "cold water at a spot ignoring comfort",
+			expected: 5.0,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -107,3 +367,220 @@ func TestCalculateScoreSpotByHour(t *testing.T) {
 		})
 	}
 }
+
+func TestScaleTide(t *testing.T) {
+	minHeight := -0.5
+	testCases := []struct {
+		tide       models.TideState
+		preference config.TidePreferenceConfig
+		expected   float64
+	}{
+		{models.TideState{Level: 0.1}, config.TidePreferenceConfig{}, 5.0},
+		{models.TideState{Level: 0.5, Rising: true}, config.TidePreferenceConfig{Stages: []string{"mid", "high"}}, 5.0},
+		{models.TideState{Level: 0.8}, config.TidePreferenceConfig{Stages: []string{"mid", "high"}}, 5.0},
+		{models.TideState{Level: 0.2}, config.TidePreferenceConfig{Stages: []string{"mid", "high"}}, 3.0000000000000004},
+		{models.TideState{Level: 0}, config.TidePreferenceConfig{Stages: []string{"high"}}, 0.0},
+		{models.TideState{Level: 0.5, Rising: false}, config.TidePreferenceConfig{Movement: "rising"}, 0.0},
+		{models.TideState{Level: 0.5, Rising: true}, config.TidePreferenceConfig{Movement: "rising"}, 5.0},
+		{models.TideState{Height: -1}, config.TidePreferenceConfig{MinHeight: &minHeight}, 2.5},
+		{models.TideState{Height: 0}, config.TidePreferenceConfig{MinHeight: &minHeight}, 5.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			t.Logf("Testing tide scale %+v against %+v", tc.tide, tc.preference)
+			result := scaleTide(tc.tide, tc.preference)
+			if result != tc.expected {
+				t.Errorf("Expected %f, got %f", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestMissingInputs(t *testing.T) {
+	weather := models.Weather{
+		WaveHeight:       models.Float(0.0),
+		SwellHeight:      models.Float(1.0),
+		SwellPeriod:      models.Float(10.0),
+		SwellDirection:   models.Float(90.0),
+		WindSpeed:        models.Float(4.0),
+		WaterTemperature: models.Float(22.0),
+	}
+
+	missing := MissingInputs(weather)
+	if len(missing) != 2 || missing[0] != "wind_direction" || missing[1] != "air_temperature" {
+		t.Errorf("Expected [wind_direction air_temperature], got %v", missing)
+	}
+}
+
+func TestComfortScoreBounds(t *testing.T) {
+	p := parametersOf(config.SpotConfig{})
+	testCases := []struct {
+		label         string
+		water, air    float64
+		precipitation float64
+		expected      float64
+	}{
+		{"ideal and dry", 22, 22, 0, 5},
+		{"ideal in a cloudburst", 22, 22, 100, 0},
+		{"freezing", 2, -5, 0, 0},
+		{"freezing in heavy rain", 2, -5, 10, 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			result, ok := calculateComfort(models.Float(tc.water), models.Float(tc.air), models.Float(tc.precipitation), p)
+			if !ok || result != tc.expected {
+				t.Errorf("Expected %f, got %f", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	testCases := []struct {
+		label   string
+		weather models.Weather
+		better  string
+		worse   string
+	}{
+		{
+			label: "small clean waves",
+			weather: models.Weather{
+				WaveHeight:     models.Float(0.7),
+				SwellHeight:    models.Float(0.7),
+				SwellPeriod:    models.Float(8.0),
+				SwellDirection: models.Float(90.0),
+				WindSpeed:      models.Float(1.0),
+				WindDirection:  models.Float(90.0),
+			},
+			better: "beginner",
+			worse:  "advanced",
+		},
+		{
+			label: "big groundswell",
+			weather: models.Weather{
+				WaveHeight:     models.Float(2.5),
+				SwellHeight:    models.Float(2.5),
+				SwellPeriod:    models.Float(14.0),
+				SwellDirection: models.Float(90.0),
+				WindSpeed:      models.Float(5.5),
+				WindDirection:  models.Float(270.0),
+			},
+			better: "advanced",
+			worse:  "beginner",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			spot := config.SpotConfig{Direction: 90}
+			betterSpot, worseSpot := spot, spot
+			betterSpot.Scoring = spot.Scoring.Merge(config.DefaultProfiles[tc.better])
+			worseSpot.Scoring = spot.Scoring.Merge(config.DefaultProfiles[tc.worse])
+
+			better := CalculateScoreSpotByHour(betterSpot, tc.weather)
+			worse := CalculateScoreSpotByHour(worseSpot, tc.weather)
+			if better <= worse {
+				t.Errorf("Expected %s to rate higher than %s, got %f and %f", tc.better, tc.worse, better, worse)
+			}
+		})
+	}
+}
+
+func TestExplainScoreSpotByHour(t *testing.T) {
+	spot := config.SpotConfig{Direction: 90, TidePreference: config.TidePreferenceConfig{Weight: 0.2, Stages: []string{"mid"}}}
+	weather := models.Weather{
+		WaveHeight:     models.Float(1.0),
+		SwellHeight:    models.Float(1.0),
+		SwellPeriod:    models.Float(10.0),
+		SwellDirection: models.Float(90.0),
+		WindSpeed:      models.Float(8.0),
+		WindDirection:  models.Float(90.0),
+		Tide:           &models.TideState{Level: 0.5, Height: 3.2, Rising: true},
+	}
+
+	score, components := ExplainScoreSpotByHour(spot, weather)
+	if score != CalculateScoreSpotByHour(spot, weather) {
+		t.Errorf("Expected the rating %f, got %f", CalculateScoreSpotByHour(spot, weather), score)
+	}
+
+	// the points lost by the components add up to the points lost by the rating
+	var lost float64
+	for _, component := range components {
+		lost += component.Weight * (component.Score - 5)
+	}
+	if math.Abs(lost-(score-5)) > 1e-9 {
+		t.Errorf("Expected %f lost, got %f", score-5, lost)
+	}
+
+	testCases := []struct {
+		name   string
+		weight float64
+		reason string
+	}{
+		{"wave", 0.8 * 0.5 / 0.95, "waves 1.0 m: 0.00"},
+		{"swell", 0.8 * 0.25 / 0.95, "swell 1.0 m, 10 s, from 90°: 0.00"},
+		{"wind", 0.8 * 0.2 / 0.95, "wind 8.0 m/s onshore, from 90°: -0.67"},
+		{"comfort", 0, "comfort unknown"},
+		{"tide", 0.2, "tide mid rising at 3.2 m: 0.00"},
+	}
+	if len(components) != len(testCases) {
+		t.Fatalf("Expected %d components, got %+v", len(testCases), components)
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			component := components[i]
+			if component.Name != tc.name || component.Reason != tc.reason {
+				t.Errorf("Expected %s %q, got %s %q", tc.name, tc.reason, component.Name, component.Reason)
+			}
+			if math.Abs(component.Weight-tc.weight) > 1e-9 {
+				t.Errorf("Expected %f, got %f", tc.weight, component.Weight)
+			}
+		})
+	}
+}
+
+func TestExplainScoreBounds(t *testing.T) {
+	spot := config.SpotConfig{Direction: 90}
+	weather := models.Weather{
+		WaveHeight:       models.Float(1.0),
+		SwellHeight:      models.Float(1.0),
+		SwellPeriod:      models.Float(10.0),
+		SwellDirection:   models.Float(90.0),
+		WindSpeed:        models.Float(4.0),
+		WindDirection:    models.Float(270.0),
+		Gust:             models.Float(40.0),
+		WaterTemperature: models.Float(10.0),
+		AirTemperature:   models.Float(8.0),
+		Precipitation:    models.Float(50.0),
+	}
+
+	score, components := ExplainScoreSpotByHour(spot, weather)
+	for _, component := range components {
+		if component.Score < 0 || component.Score > 5 {
+			t.Errorf("Expected the %s score between 0 and 5, got %f", component.Name, component.Score)
+		}
+	}
+
+	// the storm costs the whole wind and comfort weights, no more
+	testCases := []struct {
+		name   string
+		reason string
+	}{
+		{"wind", "wind 4.0 m/s offshore, from 270°, gusts 40.0 m/s: -1.00"},
+		{"comfort", "water 10 °C, air 8 °C, rain 50.0 mm/h: -0.25"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, component := range components {
+				if component.Name == tc.name && (component.Score != 0 || component.Reason != tc.reason) {
+					t.Errorf("Expected 0 %q, got %f %q", tc.reason, component.Score, component.Reason)
+				}
+			}
+		})
+	}
+	if math.Abs(score-3.75) > 1e-9 {
+		t.Errorf("Expected %f, got %f", 3.75, score)
+	}
+}
@@ -0,0 +1,134 @@
+package stormglass
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+const (
+	quotaProvider = "stormglass"
+	// free plan quota, used until a response tells us the real one
+	defaultDailyQuota = 10
+)
+
+var ErrQuotaExceeded = errors.New("stormglass daily quota exceeded")
+
+// QuotaStore persists the quota consumption so restarts don't forget it
+type QuotaStore interface {
+	GetQuota(provider string, day time.Time) (models.Quota, error)
+	SaveQuota(quota models.Quota) error
+}
+
+// QuotaTracker counts the requests sent to stormglass for the current UTC day,
+// stormglass quotas reset at midnight UTC
+type QuotaTracker struct {
+	mu         sync.Mutex
+	store      QuotaStore
+	dailyQuota int
+	quota      models.Quota
+	// requests reserved and not answered yet, they are not in the quota until their response
+	pending int
+}
+
+func NewQuotaTracker(store QuotaStore, dailyQuota int) *QuotaTracker {
+	if dailyQuota <= 0 {
+		dailyQuota = defaultDailyQuota
+	}
+	return &QuotaTracker{store: store, dailyQuota: dailyQuota}
+}
+
+func utcDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// load the quota of the current day, must be called with mu held
+func (t *QuotaTracker) current(now time.Time) models.Quota {
+	day := utcDay(now)
+	if t.quota.Day.Equal(day) {
+		return t.quota
+	}
+
+	t.quota = models.Quota{Provider: quotaProvider, Day: day, DailyQuota: t.dailyQuota}
+	if t.store != nil {
+		stored, err := t.store.GetQuota(quotaProvider, day)
+		if err == nil {
+			stored.Day = day
+			t.quota = stored
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Could not load stormglass quota: %v", err)
+		}
+	}
+	return t.quota
+}
+
+// save the quota, must be called with mu held
+func (t *QuotaTracker) save(now time.Time) {
+	t.quota.UpdatedAt = now
+	if t.store == nil {
+		return
+	}
+	if err := t.store.SaveQuota(t.quota); err != nil {
+		log.Printf("Could not save stormglass quota: %v", err)
+	}
+}
+
+// Reserve takes a slot of today's quota for one request, or returns ErrQuotaExceeded when the
+// requests used and the ones in flight leave none. The slot must be given back with Release
+// once the request is over, so that concurrent refreshes cannot overspend the quota
+func (t *QuotaTracker) Reserve(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	quota := t.current(now)
+	if quota.Remaining()-t.pending < 1 {
+		return fmt.Errorf("%w: %d/%d requests used and %d in flight, resets at %s", ErrQuotaExceeded,
+			quota.RequestCount, quota.DailyQuota, t.pending, quota.Day.Add(24*time.Hour).Format(time.RFC3339))
+	}
+	t.pending++
+	return nil
+}
+
+// Release gives back the slot taken by Reserve, after the response was recorded or the request failed
+func (t *QuotaTracker) Release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = max(0, t.pending-1)
+}
+
+// Record updates the quota from the meta block of a response, which is authoritative
+func (t *QuotaTracker) Record(meta Meta, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.current(now)
+	if meta.DailyQuota > 0 {
+		t.quota.DailyQuota = meta.DailyQuota
+	}
+	if meta.RequestCount > 0 {
+		t.quota.RequestCount = meta.RequestCount
+	} else {
+		t.quota.RequestCount += max(1, meta.Cost)
+	}
+	t.save(now)
+
+	log.Printf("Stormglass quota: %d/%d requests used today, %d remaining",
+		t.quota.RequestCount, t.quota.DailyQuota, t.quota.Remaining())
+}
+
+// Exhausted marks today's quota as fully used, when stormglass refused a request for quota reasons
+func (t *QuotaTracker) Exhausted(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.current(now)
+	t.quota.RequestCount = t.quota.DailyQuota
+	t.save(now)
+
+	log.Printf("Stormglass quota exhausted for today (%d requests)", t.quota.DailyQuota)
+}
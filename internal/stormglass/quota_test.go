@@ -0,0 +1,81 @@
+package stormglass
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"go-surf-forecast/internal/models"
+)
+
+// in memory QuotaStore
+type memoryQuotaStore map[string]models.Quota
+
+func (s memoryQuotaStore) GetQuota(provider string, day time.Time) (models.Quota, error) {
+	quota, ok := s[provider+day.Format(time.DateOnly)]
+	if !ok {
+		return models.Quota{}, sql.ErrNoRows
+	}
+	return quota, nil
+}
+
+func (s memoryQuotaStore) SaveQuota(quota models.Quota) error {
+	s[quota.Provider+quota.Day.Format(time.DateOnly)] = quota
+	return nil
+}
+
+func TestQuotaTracker(t *testing.T) {
+	store := memoryQuotaStore{}
+	now := time.Date(2024, time.October, 12, 10, 0, 0, 0, time.UTC)
+
+	tracker := NewQuotaTracker(store, 10)
+	if err := tracker.Reserve(now); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tracker.Record(Meta{Cost: 1, DailyQuota: 2, RequestCount: 2}, now)
+	tracker.Release()
+	if err := tracker.Reserve(now); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// a restarted tracker reads the consumption back from the store
+	restarted := NewQuotaTracker(store, 10)
+	if err := restarted.Reserve(now.Add(time.Hour)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded after restart, got %v", err)
+	}
+
+	// quotas reset at midnight UTC
+	if err := restarted.Reserve(now.Add(14 * time.Hour)); err != nil {
+		t.Errorf("Expected no error the next day, got %v", err)
+	}
+}
+
+func TestQuotaTrackerRequestsInFlight(t *testing.T) {
+	now := time.Date(2024, time.October, 12, 10, 0, 0, 0, time.UTC)
+	tracker := NewQuotaTracker(memoryQuotaStore{}, 2)
+
+	// two refreshes take the two slots before any response
+	for i := 0; i < 2; i++ {
+		if err := tracker.Reserve(now); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	if err := tracker.Reserve(now); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded with both slots in flight, got %v", err)
+	}
+
+	// a failed request gives its slot back
+	tracker.Release()
+	if err := tracker.Reserve(now); err != nil {
+		t.Errorf("Expected the released slot to be available, got %v", err)
+	}
+
+	// answered requests move from the slots in flight to the quota
+	tracker.Record(Meta{Cost: 1, DailyQuota: 2, RequestCount: 1}, now)
+	tracker.Release()
+	if err := tracker.Reserve(now); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded with 1 request used and 1 in flight, got %v", err)
+	}
+}
@@ -1,16 +1,20 @@
 package stormglass
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"go-surf-forecast/assets"
 	"go-surf-forecast/config"
+	"go-surf-forecast/internal/httpclient"
 )
 
 type StormglassWeatherPointApiResponse struct {
@@ -32,10 +36,72 @@ type Hour struct {
 	WavePeriod       Source    `json:"wavePeriod"`
 	WindDirection    Source    `json:"windDirection"`
 	WindSpeed        Source    `json:"windSpeed"`
+	// swell partitions, the swell above is the dominant one
+	SecondarySwellDirection Source `json:"secondarySwellDirection"`
+	SecondarySwellHeight    Source `json:"secondarySwellHeight"`
+	SecondarySwellPeriod    Source `json:"secondarySwellPeriod"`
+	WindWaveDirection       Source `json:"windWaveDirection"`
+	WindWaveHeight          Source `json:"windWaveHeight"`
+	WindWavePeriod          Source `json:"windWavePeriod"`
+	Gust                    Source `json:"gust"`
+	Precipitation           Source `json:"precipitation"`
+	CloudCover              Source `json:"cloudCover"`
+	Visibility              Source `json:"visibility"`
+	Pressure                Source `json:"pressure"`
 }
 
+// Source holds the values of one parameter by source (sg, noaa, icon, meteo, dwd...)
 type Source struct {
-	Sg float64 `json:"sg"`
+	Sg     float64            `json:"sg"`
+	Values map[string]float64 `json:"-"`
+}
+
+func (s *Source) UnmarshalJSON(data []byte) error {
+	var values map[string]float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	s.Values = values
+	s.Sg = values["sg"]
+	return nil
+}
+
+// parameters requested to the weather point endpoint
+var Params = []string{
+	"airTemperature", "currentSpeed", "seaLevel", "swellDirection", "swellHeight", "swellPeriod",
+	"waterTemperature", "waveDirection", "waveHeight", "wavePeriod", "windDirection", "windSpeed",
+	"secondarySwellDirection", "secondarySwellHeight", "secondarySwellPeriod",
+	"windWaveDirection", "windWaveHeight", "windWavePeriod",
+	"gust", "precipitation", "cloudCover", "visibility", "pressure",
+}
+
+// Parameters returns the sources of the hour by parameter name
+func (h Hour) Parameters() map[string]Source {
+	return map[string]Source{
+		"airTemperature":          h.AirTemperature,
+		"currentSpeed":            h.CurrentSpeed,
+		"seaLevel":                h.SeaLevel,
+		"swellDirection":          h.SwellDirection,
+		"swellHeight":             h.SwellHeight,
+		"swellPeriod":             h.SwellPeriod,
+		"waterTemperature":        h.WaterTemperature,
+		"waveDirection":           h.WaveDirection,
+		"waveHeight":              h.WaveHeight,
+		"wavePeriod":              h.WavePeriod,
+		"windDirection":           h.WindDirection,
+		"windSpeed":               h.WindSpeed,
+		"secondarySwellDirection": h.SecondarySwellDirection,
+		"secondarySwellHeight":    h.SecondarySwellHeight,
+		"secondarySwellPeriod":    h.SecondarySwellPeriod,
+		"windWaveDirection":       h.WindWaveDirection,
+		"windWaveHeight":          h.WindWaveHeight,
+		"windWavePeriod":          h.WindWavePeriod,
+		"gust":                    h.Gust,
+		"precipitation":           h.Precipitation,
+		"cloudCover":              h.CloudCover,
+		"visibility":              h.Visibility,
+		"pressure":                h.Pressure,
+	}
 }
 
 type Meta struct {
@@ -49,63 +115,145 @@ type Meta struct {
 	Start        string   `json:"start,omitempty"`
 }
 
-// call stormglass api endpoint v2/weather/point
-func GetStormglassWeatherDataFromApi(spot config.SpotConfig, start time.Time, duration int) (*StormglassWeatherPointApiResponse, error) {
-	cfg := config.GetConfig()
-	stormglassApiKey := cfg.Stormglass.ApiKey
-	if stormglassApiKey == "" {
-		return nil, fmt.Errorf("stormglass API key is not set in config")
+// Client calls the stormglass API through a resilient HTTP client,
+// checking and recording the daily quota when a tracker is set
+type Client struct {
+	url     string
+	apiKey  string
+	sources []string
+	http    *httpclient.Client
+	quota   *QuotaTracker
+}
+
+func NewClient(cfg config.StormglassConfig, quota *QuotaTracker) *Client {
+	sources := cfg.Sources
+	if len(sources) == 0 {
+		sources = []string{"sg"}
 	}
+	return &Client{
+		url:     cfg.Url,
+		apiKey:  cfg.ApiKey,
+		sources: sources,
+		http:    httpclient.New(cfg.Http),
+		quota:   quota,
+	}
+}
 
-	baseURL, err := url.Parse(cfg.Stormglass.Url)
-	if err != nil {
-		return nil, err
+var (
+	defaultClient *Client
+	defaultMu     sync.Mutex
+)
+
+// SetDefaultClient sets the client used by GetStormglassWeatherDataFromApi
+func SetDefaultClient(client *Client) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultClient = client
+}
+
+// DefaultClient returns the client set by SetDefaultClient, or a client
+// built from the loaded config without quota tracking
+func DefaultClient() *Client {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultClient == nil {
+		defaultClient = NewClient(config.GetConfig().Stormglass, nil)
 	}
-	baseURL.Path += "/weather/point"
+	return defaultClient
+}
 
-	params := url.Values{}
-	params.Add("lat", fmt.Sprintf("%f", spot.Lat))
-	params.Add("lng", fmt.Sprintf("%f", spot.Long))
-	params.Add("params", "airTemperature,currentSpeed,seaLevel,swellDirection,swellHeight,swellPeriod,waterTemperature,waveDirection,waveHeight,wavePeriod,windDirection,windSpeed")
-	params.Add("start", fmt.Sprintf("%d", start.Unix()))
-	end := start.Add(time.Duration(duration) * 24 * time.Hour).Unix()
-	params.Add("end", fmt.Sprintf("%d", end))
-	params.Add("source", "sg")
+// call a stormglass endpoint and decode the JSON response into v
+func (c *Client) get(ctx context.Context, path string, params url.Values, v any) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("stormglass API key is not set in config")
+	}
+
+	baseURL, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	baseURL.Path += path
 	baseURL.RawQuery = params.Encode()
 
-	log.Default().Printf("Calling stormglass API for spot %d", spot.Id)
+	if c.quota != nil {
+		if err := c.quota.Reserve(time.Now()); err != nil {
+			return err
+		}
+		defer c.quota.Release()
+	}
 
-	req, err := http.NewRequest("GET", baseURL.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", baseURL.String(), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	req.Header.Set("Authorization", stormglassApiKey)
+	req.Header.Set("Authorization", c.apiKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer resp.Body.Close()
 
+	// stormglass answers 402 once the daily quota is used
+	if resp.StatusCode == http.StatusPaymentRequired {
+		if c.quota != nil {
+			c.quota.Exhausted(time.Now())
+		}
+		return fmt.Errorf("%w: %s", ErrQuotaExceeded, resp.Status)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get data: %s", resp.Status)
+		return fmt.Errorf("failed to get data: %s", resp.Status)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return err
+	}
+
+	if c.quota != nil {
+		var response struct {
+			Meta Meta `json:"meta"`
+		}
+		if err := json.Unmarshal(body, &response); err == nil {
+			c.quota.Record(response.Meta, time.Now())
+		}
 	}
 
+	return nil
+}
+
+// call stormglass api endpoint v2/weather/point
+func (c *Client) GetWeatherPoint(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*StormglassWeatherPointApiResponse, error) {
+	params := url.Values{}
+	params.Add("lat", fmt.Sprintf("%f", spot.Lat))
+	params.Add("lng", fmt.Sprintf("%f", spot.Long))
+	params.Add("params", strings.Join(Params, ","))
+	params.Add("start", fmt.Sprintf("%d", start.Unix()))
+	end := start.Add(time.Duration(duration) * 24 * time.Hour).Unix()
+	params.Add("end", fmt.Sprintf("%d", end))
+	params.Add("source", strings.Join(c.sources, ","))
+
+	log.Default().Printf("Calling stormglass API for spot %d", spot.Id)
+
 	var weatherPointApiResponse StormglassWeatherPointApiResponse
-	if err := json.Unmarshal(body, &weatherPointApiResponse); err != nil {
+	if err := c.get(ctx, "/weather/point", params, &weatherPointApiResponse); err != nil {
 		return nil, err
 	}
 
 	return &weatherPointApiResponse, nil
 }
 
+// call stormglass api endpoint v2/weather/point with the default client
+func GetStormglassWeatherDataFromApi(spot config.SpotConfig, start time.Time, duration int) (*StormglassWeatherPointApiResponse, error) {
+	return DefaultClient().GetWeatherPoint(context.Background(), spot, start, duration)
+}
+
 // reads a static JSON file for a spot and returns the data
 func GetStormglassWeatherDataFromFile(spot config.SpotConfig, start time.Time, duration int) (*StormglassWeatherPointApiResponse, error) {
 	filePath := fmt.Sprintf("data/stormglass-data-spot-%d.json", spot.Id)
@@ -126,10 +274,7 @@ func GetStormglassWeatherDataFromFile(spot config.SpotConfig, start time.Time, d
 	for _, hour := range stormglassResponse.Hours {
 		hourTime := hour.Time
 		if hourTime.After(start) && hourTime.Before(endTime) {
-			// Keep only daylights hours
-			if hourTime.Hour() > 5 && hourTime.Hour() <= 22 {
-				filteredHours = append(filteredHours, hour)
-			}
+			filteredHours = append(filteredHours, hour)
 		}
 	}
 
@@ -1,6 +1,7 @@
 package stormglass
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -52,3 +53,39 @@ func TestGetStormglassWeatherDataFromApi(t *testing.T) {
 		t.Errorf("Expected air temperature 15.0, got %f", response.Hours[0].AirTemperature.Sg)
 	}
 }
+
+func TestClientRetriesServerErrors(t *testing.T) {
+	mockResponse, err := test.TestData.ReadFile("data/mock-stormglass-api.json")
+	if err != nil {
+		t.Fatalf("Failed to read mock response file: %v", err)
+	}
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(mockResponse))
+	}))
+	defer server.Close()
+
+	client := NewClient(config.StormglassConfig{
+		Url:    server.URL,
+		ApiKey: "fake-api-key",
+		Http:   config.HttpClientConfig{BaseBackoff: time.Millisecond},
+	}, nil)
+
+	response, err := client.GetWeatherPoint(context.Background(), config.SpotConfig{Id: 1}, time.Now(), 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+	if response.Hours[0].WaveHeight.Sg != 2.0 {
+		t.Errorf("Expected wave height 2.0, got %f", response.Hours[0].WaveHeight.Sg)
+	}
+}
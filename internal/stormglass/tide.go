@@ -0,0 +1,41 @@
+package stormglass
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"go-surf-forecast/config"
+)
+
+type StormglassTideExtremesApiResponse struct {
+	Data []TideExtreme `json:"data"`
+	Meta Meta          `json:"meta"`
+}
+
+type TideExtreme struct {
+	Height float64   `json:"height"`
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+}
+
+// call stormglass api endpoint v2/tide/extremes/point
+func (c *Client) GetTideExtremes(ctx context.Context, spot config.SpotConfig, start time.Time, duration int) (*StormglassTideExtremesApiResponse, error) {
+	params := url.Values{}
+	params.Add("lat", fmt.Sprintf("%f", spot.Lat))
+	params.Add("lng", fmt.Sprintf("%f", spot.Long))
+	params.Add("start", fmt.Sprintf("%d", start.Unix()))
+	end := start.Add(time.Duration(duration) * 24 * time.Hour).Unix()
+	params.Add("end", fmt.Sprintf("%d", end))
+
+	log.Default().Printf("Calling stormglass tide API for spot %d", spot.Id)
+
+	var tideExtremesApiResponse StormglassTideExtremesApiResponse
+	if err := c.get(ctx, "/tide/extremes/point", params, &tideExtremesApiResponse); err != nil {
+		return nil, err
+	}
+
+	return &tideExtremesApiResponse, nil
+}
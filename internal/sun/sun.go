@@ -0,0 +1,89 @@
+package sun
+
+import (
+	"math"
+	"time"
+)
+
+// zenith angle of the sun at civil dawn and dusk, 6° below the horizon
+const civilZenith = 96.0
+
+// Twilight returns the civil dawn and dusk of the solar day containing t at a place,
+// following the NOAA solar calculator. Dawn is the start of the day and dusk its end when
+// the sun never goes 6° below the horizon, both are the solar noon when it never rises above
+func Twilight(lat, lng float64, t time.Time) (time.Time, time.Time) {
+	// the solar day follows the local mean time of the place, not UTC
+	offset := time.Duration(lng / 15 * float64(time.Hour))
+	local := t.UTC().Add(offset)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC).Add(-offset)
+
+	noon, declination := solarNoon(lng, day.Add(12*time.Hour))
+
+	latRad := radians(lat)
+	cosHourAngle := math.Cos(radians(civilZenith))/(math.Cos(latRad)*math.Cos(declination)) - math.Tan(latRad)*math.Tan(declination)
+	if cosHourAngle <= -1 {
+		return day, day.Add(24 * time.Hour)
+	}
+	if cosHourAngle >= 1 {
+		return noon, noon
+	}
+
+	// the earth turns 1° in 4 minutes
+	halfDay := time.Duration(degrees(math.Acos(cosHourAngle)) * 4 * float64(time.Minute))
+	return noon.Add(-halfDay), noon.Add(halfDay)
+}
+
+// IsDaylight reports whether t is between the civil dawn and dusk of its day at a place
+func IsDaylight(lat, lng float64, t time.Time) bool {
+	dawn, dusk := Twilight(lat, lng, t)
+	return !t.Before(dawn) && !t.After(dusk)
+}
+
+// solar noon at a longitude for the day around t, and the declination of the sun in radians
+func solarNoon(lng float64, t time.Time) (time.Time, float64) {
+	julianDay := float64(t.Unix())/86400 + 2440587.5
+	centuries := (julianDay - 2451545) / 36525
+
+	meanLongitude := math.Mod(280.46646+centuries*(36000.76983+centuries*0.0003032), 360)
+	meanAnomaly := 357.52911 + centuries*(35999.05029-0.0001537*centuries)
+	eccentricity := 0.016708634 - centuries*(0.000042037+0.0000001267*centuries)
+
+	anomalyRad := radians(meanAnomaly)
+	center := math.Sin(anomalyRad)*(1.914602-centuries*(0.004817+0.000014*centuries)) +
+		math.Sin(2*anomalyRad)*(0.019993-0.000101*centuries) +
+		math.Sin(3*anomalyRad)*0.000289
+	omega := radians(125.04 - 1934.136*centuries)
+	apparentLongitude := radians(meanLongitude + center - 0.00569 - 0.00478*math.Sin(omega))
+
+	meanObliquity := 23 + (26+(21.448-centuries*(46.815+centuries*(0.00059-centuries*0.001813)))/60)/60
+	obliquity := radians(meanObliquity + 0.00256*math.Cos(omega))
+	declination := math.Asin(math.Sin(obliquity) * math.Sin(apparentLongitude))
+
+	// equation of time in minutes
+	y := math.Pow(math.Tan(obliquity/2), 2)
+	longitudeRad := radians(meanLongitude)
+	equationOfTime := 4 * degrees(y*math.Sin(2*longitudeRad)-
+		2*eccentricity*math.Sin(anomalyRad)+
+		4*eccentricity*y*math.Sin(anomalyRad)*math.Cos(2*longitudeRad)-
+		0.5*y*y*math.Sin(4*longitudeRad)-
+		1.25*eccentricity*eccentricity*math.Sin(2*anomalyRad))
+
+	utcDay := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	noonMinutes := 720 - 4*lng - equationOfTime
+	noon := utcDay.Add(time.Duration(noonMinutes * float64(time.Minute)))
+	// keep the noon of the solar day around t at far east and west longitudes
+	if diff := noon.Sub(t); diff > 12*time.Hour {
+		noon = noon.Add(-24 * time.Hour)
+	} else if diff < -12*time.Hour {
+		noon = noon.Add(24 * time.Hour)
+	}
+	return noon, declination
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,78 @@
+package sun
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTwilight(t *testing.T) {
+	testCases := []struct {
+		label    string
+		lat, lng float64
+		time     time.Time
+		dawn     time.Time
+		dusk     time.Time
+	}{
+		{
+			// civil twilight 07:56 - 19:43 CEST
+			label: "La Rochelle in October",
+			lat:   46.16, lng: -1.15,
+			time: time.Date(2024, time.October, 18, 12, 0, 0, 0, time.UTC),
+			dawn: time.Date(2024, time.October, 18, 5, 56, 0, 0, time.UTC),
+			dusk: time.Date(2024, time.October, 18, 17, 43, 0, 0, time.UTC),
+		},
+		{
+			// civil twilight 03:56 - 22:08 BST
+			label: "London at the summer solstice",
+			lat:   51.5072, lng: -0.1276,
+			time: time.Date(2024, time.June, 21, 12, 0, 0, 0, time.UTC),
+			dawn: time.Date(2024, time.June, 21, 2, 56, 0, 0, time.UTC),
+			dusk: time.Date(2024, time.June, 21, 21, 8, 0, 0, time.UTC),
+		},
+		{
+			// civil twilight 05:49 - 21:12 NZDT, the local day starts the previous UTC day
+			label: "Auckland in January",
+			lat:   -36.85, lng: 174.76,
+			time: time.Date(2024, time.January, 15, 20, 0, 0, 0, time.UTC),
+			dawn: time.Date(2024, time.January, 15, 16, 49, 0, 0, time.UTC),
+			dusk: time.Date(2024, time.January, 16, 8, 12, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			dawn, dusk := Twilight(tc.lat, tc.lng, tc.time)
+			if diff := dawn.Sub(tc.dawn); diff < -3*time.Minute || diff > 3*time.Minute {
+				t.Errorf("Expected dawn at %v, got %v", tc.dawn, dawn)
+			}
+			if diff := dusk.Sub(tc.dusk); diff < -3*time.Minute || diff > 3*time.Minute {
+				t.Errorf("Expected dusk at %v, got %v", tc.dusk, dusk)
+			}
+		})
+	}
+}
+
+func TestIsDaylight(t *testing.T) {
+	testCases := []struct {
+		label    string
+		lat, lng float64
+		time     time.Time
+		expected bool
+	}{
+		{"morning session", 46.16, -1.15, time.Date(2024, time.October, 18, 6, 0, 0, 0, time.UTC), true},
+		{"before dawn", 46.16, -1.15, time.Date(2024, time.October, 18, 5, 0, 0, 0, time.UTC), false},
+		{"evening session", 46.16, -1.15, time.Date(2024, time.October, 18, 17, 0, 0, 0, time.UTC), true},
+		{"after dusk", 46.16, -1.15, time.Date(2024, time.October, 18, 19, 0, 0, 0, time.UTC), false},
+		{"midnight sun", 78.22, 15.65, time.Date(2024, time.June, 21, 0, 0, 0, 0, time.UTC), true},
+		{"polar night", 78.22, 15.65, time.Date(2024, time.December, 21, 12, 0, 0, 0, time.UTC), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			result := IsDaylight(tc.lat, tc.lng, tc.time)
+			if result != tc.expected {
+				t.Errorf("Expected %t, got %t", tc.expected, result)
+			}
+		})
+	}
+}
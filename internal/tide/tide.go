@@ -0,0 +1,258 @@
+package tide
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+)
+
+// Constituent is one harmonic of the tide: amplitude in m and Greenwich phase lag in degrees
+type Constituent struct {
+	Name      string
+	Amplitude float64
+	Phase     float64
+}
+
+// Model predicts the tide height of a place as its mean level plus the sum of its constituents
+type Model struct {
+	Datum        float64
+	Constituents []Constituent
+}
+
+// Extreme is a high or low tide
+type Extreme struct {
+	Time   time.Time
+	Height float64
+	Type   string
+}
+
+// doodson numbers of the supported constituents: multiples of tau, s, h, p, N' and p1,
+// then the phase offset in degrees
+var doodson = map[string][7]float64{
+	"M2":  {2, 0, 0, 0, 0, 0, 0},
+	"S2":  {2, 2, -2, 0, 0, 0, 0},
+	"N2":  {2, -1, 0, 1, 0, 0, 0},
+	"K2":  {2, 2, 0, 0, 0, 0, 0},
+	"K1":  {1, 1, 0, 0, 0, 0, 90},
+	"O1":  {1, -1, 0, 0, 0, 0, -90},
+	"P1":  {1, 1, -2, 0, 0, 0, -90},
+	"Q1":  {1, -2, 0, 1, 0, 0, -90},
+	"M4":  {4, 0, 0, 0, 0, 0, 0},
+	"MS4": {4, 2, -2, 0, 0, 0, 0},
+}
+
+var j2000 = time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// NewModel builds the model of a spot from its tide config
+func NewModel(cfg config.TideConfig) (Model, error) {
+	if len(cfg.Constituents) == 0 {
+		return Model{}, fmt.Errorf("no tide constituents")
+	}
+	model := Model{Datum: cfg.Datum}
+	for _, c := range cfg.Constituents {
+		if _, ok := doodson[c.Name]; !ok {
+			return Model{}, fmt.Errorf("unknown tide constituent %q", c.Name)
+		}
+		model.Constituents = append(model.Constituents, Constituent{Name: c.Name, Amplitude: c.Amplitude, Phase: c.Phase})
+	}
+	return model, nil
+}
+
+// astronomical arguments in degrees at t: lunar time, mean longitudes of the moon, the sun,
+// the lunar perigee, the lunar ascending node and the perihelion
+type astro struct {
+	tau, s, h, p, n, p1 float64
+}
+
+func astronomy(t time.Time) astro {
+	t = t.UTC()
+	centuries := t.Sub(j2000).Hours() / 24 / 36525
+	a := astro{
+		s:  218.3164477 + 481267.88123421*centuries,
+		h:  280.46646 + 36000.76983*centuries,
+		p:  83.3532465 + 4069.0137287*centuries,
+		n:  125.04452 - 1934.136261*centuries,
+		p1: 282.93735 + 1.71946*centuries,
+	}
+	hours := float64(t.Hour()) + float64(t.Minute())/60 + float64(t.Second())/3600
+	a.tau = 15*hours + 180 + a.h - a.s
+	return a
+}
+
+// equilibrium argument V of a constituent in degrees
+func (a astro) argument(name string) float64 {
+	d := doodson[name]
+	return d[0]*a.tau + d[1]*a.s + d[2]*a.h + d[3]*a.p - d[4]*a.n + d[5]*a.p1 + d[6]
+}
+
+// nodal amplitude factor f and phase correction u in degrees of a constituent
+func (a astro) nodal(name string) (float64, float64) {
+	n := a.n * math.Pi / 180
+	fM2 := 1.0004 - 0.0373*math.Cos(n) + 0.0002*math.Cos(2*n)
+	uM2 := -2.14 * math.Sin(n)
+	switch name {
+	case "M2", "N2", "MS4":
+		return fM2, uM2
+	case "M4":
+		return fM2 * fM2, 2 * uM2
+	case "K1":
+		return 1.0060 + 0.1150*math.Cos(n) - 0.0088*math.Cos(2*n) + 0.0006*math.Cos(3*n),
+			-8.86*math.Sin(n) + 0.68*math.Sin(2*n) - 0.07*math.Sin(3*n)
+	case "O1", "Q1":
+		return 1.0089 + 0.1871*math.Cos(n) - 0.0147*math.Cos(2*n) + 0.0014*math.Cos(3*n),
+			10.80*math.Sin(n) - 1.34*math.Sin(2*n) + 0.19*math.Sin(3*n)
+	case "K2":
+		return 1.0241 + 0.2863*math.Cos(n) + 0.0083*math.Cos(2*n) - 0.0015*math.Cos(3*n),
+			-17.74*math.Sin(n) + 0.68*math.Sin(2*n) - 0.04*math.Sin(3*n)
+	}
+	return 1, 0
+}
+
+// Height returns the predicted tide height at t
+func (m Model) Height(t time.Time) float64 {
+	a := astronomy(t)
+	height := m.Datum
+	for _, c := range m.Constituents {
+		f, u := a.nodal(c.Name)
+		height += f * c.Amplitude * math.Cos((a.argument(c.Name)+u-c.Phase)*math.Pi/180)
+	}
+	return height
+}
+
+// Extremes returns the high and low tides between start and end, to the minute
+func (m Model) Extremes(start time.Time, end time.Time) []Extreme {
+	var extremes []Extreme
+	t := start.UTC().Truncate(time.Minute)
+	before, current := m.Height(t.Add(-2*time.Minute)), m.Height(t.Add(-time.Minute))
+	for ; !t.After(end); t = t.Add(time.Minute) {
+		next := m.Height(t)
+		at := t.Add(-time.Minute)
+		if !at.Before(start) {
+			if current > before && current >= next {
+				extremes = append(extremes, Extreme{Time: at, Height: current, Type: "high"})
+			}
+			if current < before && current <= next {
+				extremes = append(extremes, Extreme{Time: at, Height: current, Type: "low"})
+			}
+		}
+		before, current = current, next
+	}
+	return extremes
+}
+
+// Fit estimates the mean level and the given constituents from observed heights by least squares,
+// at least two days of hourly values are needed to separate the main constituents
+func Fit(times []time.Time, heights []float64, names []string) (Model, error) {
+	if len(times) != len(heights) {
+		return Model{}, fmt.Errorf("%d times for %d heights", len(times), len(heights))
+	}
+	for _, name := range names {
+		if _, ok := doodson[name]; !ok {
+			return Model{}, fmt.Errorf("unknown tide constituent %q", name)
+		}
+	}
+	size := 1 + 2*len(names)
+	if len(times) < size {
+		return Model{}, fmt.Errorf("%d values cannot fit %d constituents", len(times), len(names))
+	}
+
+	// normal equations of height = datum + sum f * (a cos(V+u) + b sin(V+u))
+	matrix := make([][]float64, size)
+	for i := range matrix {
+		matrix[i] = make([]float64, size+1)
+	}
+	row := make([]float64, size)
+	for k, t := range times {
+		a := astronomy(t)
+		row[0] = 1
+		for i, name := range names {
+			f, u := a.nodal(name)
+			angle := (a.argument(name) + u) * math.Pi / 180
+			row[1+2*i] = f * math.Cos(angle)
+			row[2+2*i] = f * math.Sin(angle)
+		}
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				matrix[i][j] += row[i] * row[j]
+			}
+			matrix[i][size] += row[i] * heights[k]
+		}
+	}
+
+	solution, err := solve(matrix)
+	if err != nil {
+		return Model{}, err
+	}
+
+	model := Model{Datum: solution[0]}
+	for i, name := range names {
+		a, b := solution[1+2*i], solution[2+2*i]
+		phase := math.Mod(math.Atan2(b, a)*180/math.Pi+360, 360)
+		model.Constituents = append(model.Constituents, Constituent{Name: name, Amplitude: math.Hypot(a, b), Phase: phase})
+	}
+	return model, nil
+}
+
+// solve an augmented linear system by gaussian elimination with partial pivoting
+func solve(matrix [][]float64) ([]float64, error) {
+	size := len(matrix)
+	for col := 0; col < size; col++ {
+		pivot := col
+		for row := col + 1; row < size; row++ {
+			if math.Abs(matrix[row][col]) > math.Abs(matrix[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(matrix[pivot][col]) < 1e-9 {
+			return nil, fmt.Errorf("constituents cannot be separated over this period")
+		}
+		matrix[col], matrix[pivot] = matrix[pivot], matrix[col]
+		for row := col + 1; row < size; row++ {
+			factor := matrix[row][col] / matrix[col][col]
+			for k := col; k <= size; k++ {
+				matrix[row][k] -= factor * matrix[col][k]
+			}
+		}
+	}
+
+	solution := make([]float64, size)
+	for row := size - 1; row >= 0; row-- {
+		sum := matrix[row][size]
+		for k := row + 1; k < size; k++ {
+			sum -= matrix[row][k] * solution[k]
+		}
+		solution[row] = sum / matrix[row][row]
+	}
+	return solution, nil
+}
+
+// State returns the tide at t from the surrounding extremes of tides, sorted by time, and false
+// when they do not cover t. Heights between two extremes follow a cosine
+func State(tides []models.Tide, t time.Time) (models.TideState, bool) {
+	for i := 1; i < len(tides); i++ {
+		previous, next := tides[i-1], tides[i]
+		if t.Before(previous.Time) || !t.Before(next.Time) {
+			continue
+		}
+		if previous.Type == next.Type {
+			return models.TideState{}, false
+		}
+
+		progress := t.Sub(previous.Time).Seconds() / next.Time.Sub(previous.Time).Seconds()
+		shape := (1 - math.Cos(math.Pi*progress)) / 2
+		state := models.TideState{
+			Height: previous.Height + (next.Height-previous.Height)*shape,
+			Rising: previous.Type == "low",
+		}
+		if state.Rising {
+			state.Level = shape
+		} else {
+			state.Level = 1 - shape
+		}
+		return state, true
+	}
+	return models.TideState{}, false
+}
@@ -0,0 +1,156 @@
+package tide
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"go-surf-forecast/config"
+	"go-surf-forecast/internal/models"
+)
+
+// harmonic constants of a semi-diurnal tide
+var semidiurnal = config.TideConfig{
+	Constituents: []config.TideConstituentConfig{
+		{Name: "M2", Amplitude: 1.85, Phase: 108},
+		{Name: "S2", Amplitude: 0.66, Phase: 145},
+		{Name: "N2", Amplitude: 0.38, Phase: 88},
+		{Name: "K2", Amplitude: 0.19, Phase: 142},
+		{Name: "K1", Amplitude: 0.07, Phase: 75},
+		{Name: "O1", Amplitude: 0.07, Phase: 330},
+		{Name: "M4", Amplitude: 0.08, Phase: 200},
+	},
+}
+
+func TestNewModel(t *testing.T) {
+	if _, err := NewModel(config.TideConfig{}); err == nil {
+		t.Errorf("Expected an error without constituents")
+	}
+	if _, err := NewModel(config.TideConfig{Constituents: []config.TideConstituentConfig{{Name: "X9"}}}); err == nil {
+		t.Errorf("Expected an error for an unknown constituent")
+	}
+}
+
+// the spring tides follow the new and full moons and the neap tides the quarter moons: with M2 and S2
+// in phase, the daily range must peak within a day of the moon phases published for October 2024
+func TestSpringAndNeapTides(t *testing.T) {
+	model, err := NewModel(config.TideConfig{Constituents: []config.TideConstituentConfig{
+		{Name: "M2", Amplitude: 1},
+		{Name: "S2", Amplitude: 0.5},
+	}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// daily tide ranges around October 2024
+	start := time.Date(2024, time.September, 25, 0, 0, 0, 0, time.UTC)
+	var ranges []float64
+	for day := 0; day < 40; day++ {
+		low, high := math.Inf(1), math.Inf(-1)
+		for minutes := 0; minutes < 24*60; minutes += 10 {
+			height := model.Height(start.AddDate(0, 0, day).Add(time.Duration(minutes) * time.Minute))
+			low, high = math.Min(low, height), math.Max(high, height)
+		}
+		ranges = append(ranges, high-low)
+	}
+
+	testCases := []struct {
+		label string
+		phase time.Time
+		// spring tides peak, neap tides bottom out
+		spring bool
+	}{
+		{"new moon", time.Date(2024, time.October, 2, 18, 49, 0, 0, time.UTC), true},
+		{"first quarter", time.Date(2024, time.October, 10, 18, 55, 0, 0, time.UTC), false},
+		{"full moon", time.Date(2024, time.October, 17, 11, 26, 0, 0, time.UTC), true},
+		{"last quarter", time.Date(2024, time.October, 24, 8, 3, 0, 0, time.UTC), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.label, func(t *testing.T) {
+			day := int(tc.phase.Sub(start).Hours() / 24)
+			// the extreme range of the week around the phase
+			extreme := day - 3
+			for d := day - 3; d <= day+3; d++ {
+				if (tc.spring && ranges[d] > ranges[extreme]) || (!tc.spring && ranges[d] < ranges[extreme]) {
+					extreme = d
+				}
+			}
+			if extreme < day-1 || extreme > day+1 {
+				t.Errorf("Expected the extreme range within a day of the %s on %s, got %s",
+					tc.label, start.AddDate(0, 0, day).Format(time.DateOnly), start.AddDate(0, 0, extreme).Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestFit(t *testing.T) {
+	model, err := NewModel(semidiurnal)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	model.Datum = 3.5
+
+	var times []time.Time
+	var heights []float64
+	start := time.Date(2024, time.October, 1, 0, 0, 0, 0, time.UTC)
+	for hour := 0; hour < 15*24; hour++ {
+		at := start.Add(time.Duration(hour) * time.Hour)
+		times = append(times, at)
+		heights = append(heights, model.Height(at))
+	}
+
+	names := []string{"M2", "S2", "N2", "K2", "K1", "O1", "M4"}
+	fitted, err := Fit(times, heights, names)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if math.Abs(fitted.Datum-model.Datum) > 0.001 {
+		t.Errorf("Expected datum %f, got %f", model.Datum, fitted.Datum)
+	}
+	for i, c := range fitted.Constituents {
+		want := model.Constituents[i]
+		if math.Abs(c.Amplitude-want.Amplitude) > 0.001 {
+			t.Errorf("Expected %s amplitude %f, got %f", want.Name, want.Amplitude, c.Amplitude)
+		}
+		if math.Abs(math.Remainder(c.Phase-want.Phase, 360)) > 0.1 {
+			t.Errorf("Expected %s phase %f, got %f", want.Name, want.Phase, c.Phase)
+		}
+	}
+}
+
+func TestState(t *testing.T) {
+	start := time.Date(2024, time.October, 12, 0, 0, 0, 0, time.UTC)
+	tides := []models.Tide{
+		{Time: start, Height: -2, Type: "low"},
+		{Time: start.Add(6 * time.Hour), Height: 2, Type: "high"},
+		{Time: start.Add(12 * time.Hour), Height: -1, Type: "low"},
+	}
+
+	testCases := []struct {
+		time     time.Time
+		expected models.TideState
+	}{
+		{start, models.TideState{Height: -2, Level: 0, Rising: true}},
+		{start.Add(3 * time.Hour), models.TideState{Height: 0, Level: 0.5, Rising: true}},
+		{start.Add(6 * time.Hour), models.TideState{Height: 2, Level: 1, Rising: false}},
+		{start.Add(9 * time.Hour), models.TideState{Height: 0.5, Level: 0.5, Rising: false}},
+	}
+
+	for _, tc := range testCases {
+		t.Run("", func(t *testing.T) {
+			state, ok := State(tides, tc.time)
+			if !ok {
+				t.Fatalf("Expected a tide state at %v", tc.time)
+			}
+			if math.Abs(state.Height-tc.expected.Height) > 1e-9 || math.Abs(state.Level-tc.expected.Level) > 1e-9 || state.Rising != tc.expected.Rising {
+				t.Errorf("Expected %+v, got %+v", tc.expected, state)
+			}
+		})
+	}
+
+	if _, ok := State(tides, start.Add(12*time.Hour)); ok {
+		t.Errorf("Expected no tide state after the last extreme")
+	}
+}